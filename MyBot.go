@@ -34,10 +34,14 @@ package main
 */
 
 import (
+    "flag"
     "fmt"
     "math"
-    "math/rand"
+    "os"
+    "path/filepath"
+    "runtime/debug"
     "sort"
+    "strings"
     "time"
 
     hal "./gohalite"
@@ -54,6 +58,17 @@ const (
     MAX_OPENING_COMBO = 4
 )
 
+var TIMING_PHASES = []string{"parse", "FixNiceMin", "War", "OpeningFingers", "AttractionMap_v2", "checker_penalty", "Attract", "ForcedAttacks", "send"}
+
+// The offline modes (golden-move suite, determinism auditor, move explainer) are only built
+// with -tags tools, so that MyBot.go still builds on its own, the way the competition builds
+// it. Each declares its own flags and adds itself here; main() runs the first one that was
+// asked for, and exits.
+
+type OfflineMode func() (ran bool, ok bool)
+
+var OfflineModes []OfflineMode
+
 var TotalSimPos int
 var BestNiceMin int
 var NiceMin int
@@ -61,29 +76,122 @@ var OpeningEvalDepth int
 
 func main() {
 
+    json_protocol := flag.Bool("json", false, "speak the JSON-lines protocol instead of the classic one")
+    snapshot_turn := flag.Int("snapshot", -1, "save a snapshot of the position at the start of this turn")
+    load_file := flag.String("load", "", "load a snapshot, make moves for it, and exit")
+    trace_file := flag.String("trace", "", "write a structured decision trace (NDJSON) to this file")
+    log_level := flag.String("log-level", "info", "lowest log level written: debug, info, warn or error (lower levels are kept in memory until an error)")
+    log_categories := flag.String("log-categories", "", "log categories to write, e.g. \"war,attract\" or \"-timing\" (opening, war, attract, timing)")
+    log_json := flag.Bool("log-json", false, "write the log as one JSON object per line")
+    log_dir := flag.String("log-dir", ".", "directory for this game's log, e.g. where the local engine writes its replays")
+    log_max_files := flag.Int("log-max-files", 100, "keep at most this many of this version's logs in -log-dir, counting the new one (0: no limit)")
+    log_max_bytes := flag.Int64("log-max-bytes", 200 * 1024 * 1024, "delete the oldest of this version's logs in -log-dir beyond this total size (0: no limit)")
+    timing_csv := flag.String("timing-csv", "", "write per-turn, per-phase timings to this CSV file")
+    profile_slow := flag.Float64("profile-slow", 0, "after a turn taking more than this fraction of TIMEOUT, dump it and CPU profile the next turns (0: off)")
+    profile_turns := flag.Int("profile-turns", 3, "with -profile-slow, how many turns each profile covers")
+    profile_max := flag.Int("profile-max", 3, "with -profile-slow, the most profiles to take in one game")
+    flag.Parse()
+
+    if *json_protocol {
+        hal.Protocol = hal.PROTOCOL_JSON
+    }
+
+    if *load_file != "" {
+        RunSnapshot(*load_file)
+        return
+    }
+
+    for _, mode := range OfflineModes {
+        ran, ok := mode()
+        if ran {
+            if ok == false {
+                os.Exit(1)
+            }
+            return
+        }
+    }
+
+    var pruned int
+    var prune_err error
+
+    if LOGGING_ENABLED {
+        pruned, prune_err = hal.PruneLogs(*log_dir, NAME, *log_max_files, *log_max_bytes)     // Before the engine's clock is running
+    }
+
     g := new(hal.Game)
-    g.Logfile = hal.NewLog("Log" + "_" + NAME + ".log", LOGGING_ENABLED)
+    g.Logfile = hal.NewLog(filepath.Join(*log_dir, "Log" + "_" + NAME + ".log"), LOGGING_ENABLED)    // Renamed below
+    g.Logfile.SetCategories(*log_categories)
+    g.Logfile.JSON = *log_json
+    if level, err := hal.ParseLogLevel(*log_level); err == nil {
+        g.Logfile.Level = level
+    } else {
+        fmt.Fprintln(os.Stderr, err)
+    }
     g.Startup()
 
+    g.Logfile.SetFilename(filepath.Join(*log_dir, g.GameLogName(NAME)))     // Now we know the id and map size
+
+    if prune_err != nil {
+        g.LogWarn(hal.CAT_GENERAL, "%v", prune_err)
+    }
+    if pruned > 0 {
+        g.Log("Deleted %d old log files", pruned)
+    }
+
+    var timing_err error
+    g.Timing, timing_err = hal.NewPhaseTimer(*timing_csv, TIMING_PHASES)
+    if timing_err != nil {
+        g.LogWarn(hal.CAT_TIMING, "Couldn't open timing CSV: %v", timing_err)
+    }
+
+    if *profile_slow > 0 {
+        g.SlowTurns = hal.NewSlowTurnProfiler(GameFilePrefix(g), *profile_slow, *profile_turns, *profile_max)
+    }
+
+    if *trace_file != "" {
+        var err error
+        g.Trace, err = hal.NewTracer(*trace_file)
+        if err != nil {
+            g.LogWarn(hal.CAT_GENERAL, "Couldn't open trace file: %v", err)
+        }
+    }
+
     var longest_ponder time.Duration
     var longest_ponder_turn int
     var total_overallocation int
 
     g.Log("----------------------------------------------------------------")
 
-    best_opening := AI_Startup(g)
+    best_opening := SafeAI_Startup(g)
 
-    fmt.Printf("%s\n", NAME)                        // Tell the engine we're ready
+    hal.SendName(NAME)                              // Tell the engine we're ready
 
-    rand.Seed(1)                                    // Match the seed used by the simulations (do this last, before real loop)
+    hal.SeedRNG(1)                                  // Match the seed used by the simulations (do this last, before real loop)
 
     for {
 
         // Main loop...
 
         g.Update()
-        MakeMoves(g, best_opening)
+        g.TimePhase("parse", g.TurnStart)
+
+        if g.Turn == *snapshot_turn {
+            filename := fmt.Sprintf("Snapshot_%s_turn%d.gob", NAME, g.Turn)
+            err := SaveBotSnapshot(g, best_opening, filename)
+            if err != nil {
+                g.LogWarn(hal.CAT_GENERAL, "Turn %d: couldn't save snapshot: %v", g.Turn, err)
+            }
+        }
+
+        SafeMakeMoves(g, best_opening)
+
+        send_start := time.Now()
         g.SendMoves()
+        g.TimePhase("send", send_start)
+
+        g.Trace.Flush(g)
+        g.Timing.EndTurn(g)
+        g.SlowTurns.AfterTurn(g)
 
         // The rest is just logging...
 
@@ -98,8 +206,8 @@ func main() {
             g.LogOnce("OpeningFlag became false on turn %d", g.Turn)
         }
 
-        if time.Since(g.TurnStart) >= hal.TIMEOUT {
-            g.Log("Turn %d: Apparently timed out", g.Turn)
+        if time.Since(g.TurnStart) > hal.TIMEOUT {              // The engine's limit, not our (possibly reduced) budget
+            g.LogWarn(hal.CAT_TIMING, "Turn %d: Apparently timed out", g.Turn)
         }
 
         MaybeLogEnd(g, longest_ponder, longest_ponder_turn)
@@ -134,13 +242,76 @@ func MaybeLogEnd(g *hal.Game, longest_ponder time.Duration, longest_ponder_turn
     }
 
     if didlog {
-        g.Log("Longest ponder by turn %d: %v (turn %d)", g.Turn, longest_ponder, longest_ponder_turn)
+        g.LogCat(hal.CAT_TIMING, "Longest ponder by turn %d: %v (turn %d)", g.Turn, longest_ponder, longest_ponder_turn)
         g.LogBoardHash()
+        g.LogTimingSummary()
     }
 
     return didlog
 }
 
+// -------------------------------------------------------------------------------------------------------------
+// Snapshots. The Game is saved in full by the library; our own globals go alongside it,
+// as does the RNG's state (seed and draw count), so a reloaded position gets exactly the
+// same shuffles, and hence the same moves, as the original game.
+
+func SaveBotSnapshot(g *hal.Game, opening_combo []int, filename string) error {
+
+    s := g.MakeSnapshot()
+
+    s.BotInts["NiceMin"] = NiceMin
+    s.BotInts["BestNiceMin"] = BestNiceMin
+    s.BotInts["OpeningEvalDepth"] = OpeningEvalDepth
+    s.BotInts["TotalSimPos"] = TotalSimPos
+    s.BotLists["OpeningCombo"] = opening_combo
+
+    seed, draws := hal.RNGSource.State()
+    s.BotInts["RNGSeed"] = int(seed)
+    s.BotInts["RNGDraws"] = int(draws)
+
+    return s.Save(filename)
+}
+
+func LoadBotSnapshot(filename string) (*hal.Game, []int, error) {
+
+    s, err := hal.LoadSnapshot(filename)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    NiceMin = s.BotInts["NiceMin"]
+    BestNiceMin = s.BotInts["BestNiceMin"]
+    OpeningEvalDepth = s.BotInts["OpeningEvalDepth"]
+    TotalSimPos = s.BotInts["TotalSimPos"]
+
+    seed, ok := s.BotInts["RNGSeed"]
+    if ok == false {
+        seed = 1                        // Older snapshots; the best we can do
+    }
+    hal.RNGSource.Restore(int64(seed), int64(s.BotInts["RNGDraws"]))
+
+    return s.Game, s.BotLists["OpeningCombo"], nil
+}
+
+func RunSnapshot(filename string) {
+
+    g, opening_combo, err := LoadBotSnapshot(filename)
+    if err != nil {
+        fmt.Println(err)
+        return
+    }
+
+    g.Logfile = hal.NewLog("Log" + "_" + NAME + "_snapshot.log", LOGGING_ENABLED)
+    g.TurnStart = time.Now()            // Otherwise the deadline has long since passed
+
+    SafeMakeMoves(g, opening_combo)
+
+    g.Log("Snapshot %s, turn %d", filename, g.Turn)
+    g.LogMoves()
+
+    fmt.Printf("Turn %d -- Board SHA1: %s -- Moves SHA1: %s\n", g.Turn, g.BoardHash(), g.MovesHash())
+}
+
 // -------------------------------------------------------------------------------------------------------------
 
 func AI_Startup(g *hal.Game) []int {
@@ -165,7 +336,7 @@ func AI_Startup(g *hal.Game) []int {
             return SimulateOpenings(g)
         }
     } else {
-        g.Log("Apparently loaded into midgame.")
+        g.LogCat(hal.CAT_OPENING, "Apparently loaded into midgame.")
         return nil
     }
 }
@@ -177,7 +348,7 @@ type BestCombo struct {
 
 func SimulateOpenings(g *hal.Game) []int {
 
-    g.Log("Using SimulateOpenings()")
+    g.LogCat(hal.CAT_OPENING, "Using SimulateOpenings()")
 
     nil_result := EvaluateCombo(g, nil)
 
@@ -188,16 +359,16 @@ func SimulateOpenings(g *hal.Game) []int {
     abortflag := Recursor(g, combo, &bc)
 
     if abortflag {
-        g.Log("Search was aborted due to time.")
+        g.LogCat(hal.CAT_OPENING, "Search was aborted due to time.")
     }
 
-    g.Log("%d total simulated positions; depth: %d, time taken: %v", TotalSimPos, OpeningEvalDepth, time.Since(g.GameStart))
+    g.LogCat(hal.CAT_OPENING, "%d total simulated positions; depth: %d, time taken: %v", TotalSimPos, OpeningEvalDepth, time.Since(g.GameStart))
 
     if bc.score > nil_result {
-        g.Log("Using combo: %v, score: %d (nil score: %d)", bc.combo, bc.score, nil_result)
+        g.LogCat(hal.CAT_OPENING, "Using combo: %v, score: %d (nil score: %d)", bc.combo, bc.score, nil_result)
         return bc.combo
     } else {
-        g.Log("NOT using combo: %v, score: %d (nil score: %d)", bc.combo, bc.score, nil_result)
+        g.LogCat(hal.CAT_OPENING, "NOT using combo: %v, score: %d (nil score: %d)", bc.combo, bc.score, nil_result)
         return nil
     }
 
@@ -208,7 +379,7 @@ func SimulateOpenings(g *hal.Game) []int {
 
 func Recursor(g *hal.Game, combo []int, bc *BestCombo) bool {
 
-    if time.Since(g.GameStart) > hal.INITIAL_TIMEOUT {
+    if g.StartupDeadline().Expired() {
         return true
     }
 
@@ -262,7 +433,7 @@ func Recursor(g *hal.Game, combo []int, bc *BestCombo) bool {
 
 func CheapOpeningTester(g *hal.Game) []int {
 
-    g.Log("Using CheapOpeningTester()")
+    g.LogCat(hal.CAT_OPENING, "Using CheapOpeningTester()")
 
     U, R, D, L := hal.UP, hal.RIGHT, hal.DOWN, hal.LEFT
 
@@ -310,25 +481,27 @@ func CheapOpeningTester(g *hal.Game) []int {
             copy(bc.combo, combo)
         }
         if score == -1 {        // Emergency timeout initiated in EvaluateCombo()
-            g.Log("Search was aborted due to time.")
+            g.LogCat(hal.CAT_OPENING, "Search was aborted due to time.")
             break
         }
     }
 
-    g.Log("%d total simulated positions; depth: %d, time taken: %v", TotalSimPos, OpeningEvalDepth, time.Since(g.GameStart))
+    g.LogCat(hal.CAT_OPENING, "%d total simulated positions; depth: %d, time taken: %v", TotalSimPos, OpeningEvalDepth, time.Since(g.GameStart))
 
     if bc.score > nil_result {
-        g.Log("Using combo: %v, score: %d (nil score: %d)", bc.combo, bc.score, nil_result)
+        g.LogCat(hal.CAT_OPENING, "Using combo: %v, score: %d (nil score: %d)", bc.combo, bc.score, nil_result)
         return bc.combo
     } else {
-        g.Log("NOT using combo: %v, score: %d (nil score: %d)", bc.combo, bc.score, nil_result)
+        g.LogCat(hal.CAT_OPENING, "NOT using combo: %v, score: %d (nil score: %d)", bc.combo, bc.score, nil_result)
         return nil
     }
 }
 
 func EvaluateCombo(realgame *hal.Game, combo []int) int {
 
-    rand.Seed(1)
+    hal.SeedRNG(1)
+
+    deadline := realgame.StartupDeadline()
 
     s := hal.NewOpeningSimulator(realgame, realgame.Id)
     s.G.OpeningFlag = true
@@ -337,13 +510,13 @@ func EvaluateCombo(realgame *hal.Game, combo []int) int {
 
     for n := 0 ; n < OpeningEvalDepth ; n++ {
 
-        if time.Since(s.G.GameStart) > hal.INITIAL_TIMEOUT {          // Emergency timeout.
+        if deadline.Expired() {                                       // Emergency timeout.
             return -1
         }
 
         MakeMoves(s.G, combo)
 
-        if time.Since(s.G.GameStart) > hal.INITIAL_TIMEOUT {          // Emergency timeout.
+        if deadline.Expired() {                                       // Emergency timeout.
             return -1
         }
 
@@ -419,7 +592,22 @@ func FixNiceMin(g *hal.Game) {
 
 func MakeMoves(g *hal.Game, opening_combo []int) {
 
+    // The deadline is only consulted for optional work. In the real game, if time runs
+    // short we skip whatever is left and the moves decided so far get sent. Sims run
+    // during startup and are policed by EvaluateCombo() instead, so they never skip
+    // anything here: a sim scored on truncated moves would mislead the opening search.
+    // Nor do the offline tools, whose results mustn't depend on how loaded the machine is.
+
+    var deadline *hal.Deadline
+    if g.IsSim || g.Untimed {
+        deadline = hal.NoDeadline()
+    } else {
+        deadline = g.TurnDeadline()
+    }
+
+    start := time.Now()
     FixNiceMin(g)
+    g.TimePhase("FixNiceMin", start)
 
     if len(opening_combo) == 0 {
         g.OpeningFlag = false
@@ -428,34 +616,137 @@ func MakeMoves(g *hal.Game, opening_combo []int) {
     var war_zones []int
 
     if g.IsSim == false {
+        start = time.Now()
         war_zones = g.ListWarZones()
 
         if len(war_zones) > 0 {
             g.OpeningFlag = false
+            g.LogDebug(hal.CAT_WAR, "Turn %d: %d war zones", g.Turn, len(war_zones))
             War(g, war_zones)
         }
+        g.TimePhase("War", start)
+    }
+
+    if deadline.Near() {
+        g.LogWarn(hal.CAT_TIMING, "Turn %d: deadline near after War(), %v remaining", g.Turn, deadline.Remaining())
+        return
     }
 
     if g.OpeningFlag {
+        start = time.Now()
         err := OpeningFingers(g, opening_combo)
         if err != nil {
             g.OpeningFlag = false
         }
+        g.TimePhase("OpeningFingers", start)
     }
 
     if g.OpeningFlag == false {
 
+        start = time.Now()
         attraction_map, target_distances := g.AttractionMap_v2(NiceMin)
+        g.TimePhase("AttractionMap_v2", start)
 
-        if len(war_zones) > 0 {
+        if len(war_zones) > 0 && deadline.Near() == false {
+            start = time.Now()
             checker_penalty(g, attraction_map)
+            g.TimePhase("checker_penalty", start)
         }
 
-        Attract(g, attraction_map, target_distances)
+        g.TraceAttractionMap(attraction_map)
+
+        start = time.Now()
+        Attract(g, attraction_map, target_distances, deadline.Sub(0.8))
+        g.TimePhase("Attract", start)
+
+        start = time.Now()
         ForcedAttacks(g)
+        g.TimePhase("ForcedAttacks", start)
     }
 }
 
+func SafeMakeMoves(g *hal.Game, opening_combo []int) (ok bool) {
+
+    // A panic anywhere in the AI would otherwise kill the bot and forfeit the game.
+    // Instead, log everything useful, dump the position, and send a safe move set:
+    // the moves decided before the panic if they look sane, otherwise all STILL.
+
+    defer func() {
+        r := recover()
+        if r == nil {
+            return
+        }
+
+        ok = false
+
+        LogPanic(g, r, "MakeMoves()")
+
+        for i := 0 ; i < g.Size ; i++ {
+            if g.Moves[i] < hal.STILL || g.Moves[i] > hal.WEST {
+                g.LogError(hal.CAT_GENERAL, "Turn %d: moves were corrupt, sending all STILL", g.Turn)
+                g.ClearMoves()
+                break
+            }
+        }
+    }()
+
+    MakeMoves(g, opening_combo)
+    return true
+}
+
+func SafeAI_Startup(g *hal.Game) (best_opening []int) {
+
+    // As SafeMakeMoves(), but for the opening search, which runs MakeMoves() in its sims.
+    // Without an opening plan we still play, just less well.
+
+    defer func() {
+        r := recover()
+        if r == nil {
+            return
+        }
+
+        LogPanic(g, r, "AI_Startup()")
+        g.LogError(hal.CAT_OPENING, "Continuing without an opening plan")
+
+        g.ClearMoves()
+        best_opening = nil
+    }()
+
+    return AI_Startup(g)
+}
+
+func LogPanic(g *hal.Game, r interface{}, during string) {
+
+    // Log everything useful about a recovered panic, and dump the position next to the log.
+
+    g.LogError(hal.CAT_GENERAL, "Turn %d: PANIC in %s: %v", g.Turn, during, r)
+    g.LogError(hal.CAT_GENERAL, "%s", debug.Stack())
+    g.LogMovementNotes()
+    g.LogBoardHash()
+
+    filename := fmt.Sprintf("%s_panic_turn%d.hlt", GameFilePrefix(g), g.Turn)
+    if g.Turn < 0 {
+        filename = fmt.Sprintf("%s_panic_startup.hlt", GameFilePrefix(g))     // Before the first frame
+    }
+    err := g.DumpHLT(filename)
+    if err != nil {
+        g.LogError(hal.CAT_GENERAL, "Couldn't dump %s: %v", filename, err)
+    } else {
+        g.Log("Position dumped to %s", filename)
+    }
+}
+
+func GameFilePrefix(g *hal.Game) string {
+
+    // Per-game files (panic dumps, profiles...) are named after the log, so they land in the
+    // same directory and are pruned along with it.
+
+    if g.Logfile.Filename() == "" {
+        return "Log_" + NAME
+    }
+    return strings.TrimSuffix(g.Logfile.Filename(), hal.LOG_SUFFIX)
+}
+
 // -------------------------------------------------------------------------------------------------------------
 
 func War(g *hal.Game, war_zones []int) {
@@ -489,6 +780,7 @@ func War(g *hal.Game, war_zones []int) {
             neigh_i := neighbour.Index
 
             if g.Owner[neigh_i] != 0 {
+                g.TraceCandidate(i, "War", neighbour.Dir, 0, "not neutral")
                 continue
             }
 
@@ -505,7 +797,12 @@ func War(g *hal.Game, war_zones []int) {
                 if g.Allocation[neigh_i] + g.Strength[i] <= 255 {   // Suicide prevention
                     best_score = score
                     best_move = g.Cardinal(i, neigh_i)
+                    g.TraceCandidate(i, "War", neighbour.Dir, float64(score), "")
+                } else {
+                    g.TraceCandidate(i, "War", neighbour.Dir, float64(score), "255 cap")
                 }
+            } else {
+                g.TraceCandidate(i, "War", neighbour.Dir, float64(score), "score not above best")
             }
         }
 
@@ -607,12 +904,17 @@ func OneFinger(g *hal.Game, puller_index int) bool {
 
 // -------------------------------------------------------------------------------------------------------------
 
-func Attract(g *hal.Game, attraction_map []int, target_distances []int) {
+func Attract(g *hal.Game, attraction_map []int, target_distances []int, deadline *hal.Deadline) {
 
     cells_by_dist := g.ListFriendliesByValue(target_distances)
 
     for dist := 1 ; dist < len(cells_by_dist) ; dist++ {        // Make a pass over the internal pieces, from rim to hub...
 
+        if deadline.Expired() {                                 // Rim pieces were done first, so stopping here costs little
+            g.LogWarn(hal.CAT_TIMING, "Turn %d: Attract() stopped early at distance %d", g.Turn, dist)
+            break
+        }
+
         tmp := hal.SortStruct{g, cells_by_dist[dist]}
         sort.Sort(sort.Reverse(hal.ByStrength(tmp)))
 
@@ -623,6 +925,9 @@ func Attract(g *hal.Game, attraction_map []int, target_distances []int) {
             }
 
             if g.Strength[i] < g.Production[i] * INTERNAL_MULTIPLIER {
+                if g.Trace != nil {
+                    g.TraceReason(i, "Attract", fmt.Sprintf("held back: strength %d < production %d * INTERNAL_MULTIPLIER %d", g.Strength[i], g.Production[i], INTERNAL_MULTIPLIER))
+                }
                 continue
             }
 
@@ -630,6 +935,8 @@ func Attract(g *hal.Game, attraction_map []int, target_distances []int) {
             preferred_dir := hal.STILL
             best_index := i
 
+            g.TraceCandidate(i, "Attract", hal.STILL, float64(attraction_map[i]), "")
+
             for _, neighbour := range g.Neighbours[i] {
                 neigh_i := neighbour.Index
 
@@ -638,6 +945,8 @@ func Attract(g *hal.Game, attraction_map []int, target_distances []int) {
 
                 if projected_allocation <= 255 || g.TouchesNeutral(neigh_i) && g.Moves[neigh_i] == hal.STILL && projected_incoming <= 255 {
 
+                    g.TraceCandidate(i, "Attract", neighbour.Dir, float64(attraction_map[neigh_i]), "")
+
                     if attraction_map[neigh_i] > best {
 
                         best = attraction_map[neigh_i]
@@ -653,12 +962,17 @@ func Attract(g *hal.Game, attraction_map []int, target_distances []int) {
                             best_index = neigh_i
                         }
                     }
+
+                } else {
+                    g.TraceCandidate(i, "Attract", neighbour.Dir, float64(attraction_map[neigh_i]), "255 cap")
                 }
             }
 
             if preferred_dir != hal.STILL {
                 if g.Owner[best_index] != 0 || g.Strength[best_index] < g.Strength[i] || (g.Strength[best_index] == 255 && g.Strength[i] == 255) {
                     g.SetMove(i, preferred_dir, "Attract")
+                } else {
+                    g.TraceReason(i, "Attract", "best target is a neutral we can't beat")
                 }
             }
         }
@@ -690,7 +1004,10 @@ func Attract(g *hal.Game, attraction_map []int, target_distances []int) {
                 if g.Strength[best_index] < g.Strength[i] {
                     projected_allocation := g.Allocation[best_index] + g.Strength[i]
                     if projected_allocation <= 255 {
+                        g.TraceReason(i, "Attract", "obvious capture despite INTERNAL_MULTIPLIER")
                         g.SetMove(i, preferred_dir, "Attract")
+                    } else {
+                        g.TraceCandidate(i, "Attract", preferred_dir, float64(best), "255 cap")
                     }
                 }
             }
@@ -741,8 +1058,10 @@ func ForcedAttacks(g *hal.Game) {
                 for _, tar_i := range tar_indices {
                     if g.Allocation[tar_i] + g.Strength[i] <= 255 {
                         forced_dir = g.Cardinal(i, tar_i)
+                        g.TraceCandidate(i, "ForcedAttacks", forced_dir, float64(g.Goodness(tar_i)), "")
                         break
                     }
+                    g.TraceCandidate(i, "ForcedAttacks", g.Cardinal(i, tar_i), float64(g.Goodness(tar_i)), "255 cap")
                 }
             }
 
@@ -758,13 +1077,15 @@ func ForcedAttacks(g *hal.Game) {
                     tmp := hal.SortStruct{g, ret_indices}
                     sort.Sort(hal.ByAllocation(tmp))
                     forced_dir = g.Cardinal(i, ret_indices[0])
+                    g.TraceReason(i, "ForcedAttacks", fmt.Sprintf("no safe target, retreating to lowest allocation (%d)", g.Allocation[ret_indices[0]]))
                 }
             }
 
             if forced_dir != -1 {
+                g.TraceReason(i, "ForcedAttacks", fmt.Sprintf("allocation %d would exceed 255", g.Allocation[i]))
                 g.SetMove(i, forced_dir, "ForcedAttacks")
-                // x, y := g.I_to_XY(i)
-                // g.Log("Turn %d: unit %d [%d,%d] went kamikaze %s", g.Turn, i, x, y, hal.Dir_to_str(forced_dir))
+                x, y := g.I_to_XY(i)
+                g.LogDebug(hal.CAT_ATTRACT, "Turn %d: unit %d [%d,%d] went kamikaze %s", g.Turn, i, x, y, hal.Dir_to_str(forced_dir))
             }
         }
     }
@@ -775,7 +1096,7 @@ func ForcedAttacks(g *hal.Game) {
 
 func shuffle(src []int) {
     dest := make([]int, len(src))
-    perm := rand.Perm(len(src))
+    perm := hal.RNG.Perm(len(src))
     for i, v := range perm {
         dest[v] = src[i]
     }
@@ -0,0 +1,150 @@
+//go:build tools
+// +build tools
+
+package main
+
+// Determinism auditor. Runs the bot several times on the same position (or every position
+// of a replay), under different GOMAXPROCS settings, and reports any turn where the moves
+// differ between runs. Go randomises map iteration order on every range, so repeated runs
+// also exercise that. For each divergence, the routine responsible is named from the
+// MovementNotes: the earliest routine (in MakeMoves() order) to have given a differing order.
+
+import (
+    "flag"
+    "fmt"
+    "runtime"
+
+    hal "./gohalite"
+)
+
+var audit_file = flag.String("audit", "", "check that moves are deterministic for positions in this replay, and exit")
+var audit_runs = flag.Int("runs", 4, "with -audit, how many times to run each position")
+
+func init() {
+    OfflineModes = append(OfflineModes, func() (bool, bool) {
+        if *audit_file == "" {
+            return false, false
+        }
+        return true, RunAudit(*audit_file, *position_player, *position_turn, *audit_runs)
+    })
+}
+
+var ROUTINE_ORDER = []string{"War", "OneFinger", "Attract", "ForcedAttacks"}
+
+type AuditRun struct {
+    MovesHash       string
+    Moves           []int
+    Notes           []string
+}
+
+func audit_position(hlt *hal.HLT, turn int, id int, runs int) ([]AuditRun, error) {
+
+    var result []AuditRun
+
+    original_procs := runtime.GOMAXPROCS(0)
+    defer runtime.GOMAXPROCS(original_procs)
+
+    for n := 0 ; n < runs ; n++ {
+
+        runtime.GOMAXPROCS(1 + n % runtime.NumCPU())
+
+        g, err := PositionFromHLT(hlt, turn, id)
+        if err != nil {
+            return nil, err
+        }
+
+        MovesFromPosition(g)
+
+        run := AuditRun{MovesHash: g.MovesHash()}
+        run.Moves = append(run.Moves, g.Moves...)
+        for i := 0 ; i < g.Size ; i++ {
+            if g.HasOrders[i] {
+                run.Notes = append(run.Notes, g.MovementNotes[i])
+            } else {
+                run.Notes = append(run.Notes, "")
+            }
+        }
+
+        result = append(result, run)
+    }
+
+    return result, nil
+}
+
+func first_diverging_routine(a, b AuditRun) (string, int) {
+
+    // Returns the earliest routine with a differing order, and the number of differing cells.
+
+    best := len(ROUTINE_ORDER)
+    cells := 0
+    other := ""
+
+    for i := range a.Moves {
+
+        if a.Moves[i] == b.Moves[i] && a.Notes[i] == b.Notes[i] {
+            continue
+        }
+
+        cells++
+
+        for _, note := range []string{a.Notes[i], b.Notes[i]} {
+            found := false
+            for r, routine := range ROUTINE_ORDER {
+                if note == routine {
+                    found = true
+                    if r < best {
+                        best = r
+                    }
+                }
+            }
+            if found == false && note != "" {
+                other = note
+            }
+        }
+    }
+
+    if best < len(ROUTINE_ORDER) {
+        return ROUTINE_ORDER[best], cells
+    }
+    if other != "" {
+        return other, cells
+    }
+    return "unknown", cells
+}
+
+func RunAudit(filename string, id int, turn int, runs int) bool {
+
+    hlt, err := hal.LoadHLT(filename)
+    if err != nil {
+        fmt.Println(err)
+        return false
+    }
+
+    first, last := turn, turn
+    if turn < 0 {
+        first, last = 0, len(hlt.Frames) - 1
+    }
+
+    bad_turns := 0
+
+    for t := first ; t <= last ; t++ {
+
+        results, err := audit_position(hlt, t, id, runs)
+        if err != nil {
+            fmt.Println(err)
+            return false
+        }
+
+        for n := 1 ; n < len(results) ; n++ {
+            if results[n].MovesHash != results[0].MovesHash {
+                routine, cells := first_diverging_routine(results[0], results[n])
+                fmt.Printf("Turn %d: run %d differs from run 0 in %d cells; first diverging routine: %s\n", t, n, cells, routine)
+                bad_turns++
+                break
+            }
+        }
+    }
+
+    fmt.Printf("%d of %d turns nondeterministic over %d runs each\n", bad_turns, last - first + 1, runs)
+    return bad_turns == 0
+}
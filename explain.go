@@ -0,0 +1,131 @@
+//go:build tools
+// +build tools
+
+package main
+
+// Explain-this-move. Rebuilds a position from a replay, makes moves for it with the decision
+// trace on, and prints why one cell moved or stayed: which routine gave the order, what the
+// candidates scored, the neighbours' attraction scores and allocations, and whether Attract()'s
+// INTERNAL_MULTIPLIER threshold held it back. Replaces squinting at LogAttractMap() output.
+
+import (
+    "flag"
+    "fmt"
+
+    hal "./gohalite"
+)
+
+var explain_file = flag.String("explain", "", "explain the order given to one cell (-x, -y) in a replay position, and exit")
+var explain_x = flag.Int("x", 0, "with -explain, the cell's x")
+var explain_y = flag.Int("y", 0, "with -explain, the cell's y")
+
+func init() {
+    OfflineModes = append(OfflineModes, func() (bool, bool) {
+        if *explain_file == "" {
+            return false, false
+        }
+        return true, RunExplain(*explain_file, *position_player, *position_turn, *explain_x, *explain_y)
+    })
+}
+
+func RunExplain(filename string, id int, turn int, x int, y int) bool {
+
+    hlt, err := hal.LoadHLT(filename)
+    if err != nil {
+        fmt.Println(err)
+        return false
+    }
+
+    if turn < 0 || turn >= len(hlt.Frames) {
+        fmt.Printf("Turn %d is not in %s (it has %d frames)\n", turn, filename, len(hlt.Frames))
+        return false
+    }
+
+    if x < 0 || x >= hlt.Width || y < 0 || y >= hlt.Height {
+        fmt.Printf("(%d,%d) is not on the %dx%d board\n", x, y, hlt.Width, hlt.Height)
+        return false
+    }
+
+    g, err := PositionFromHLT(hlt, turn, id)
+    if err != nil {
+        fmt.Println(err)
+        return false
+    }
+
+    war_zones := len(g.ListWarZones())
+
+    g.Trace = hal.NewMemoryTracer()
+    MovesFromPosition(g)
+
+    i := g.XY_to_I(x, y)
+
+    fmt.Printf("Turn %d, player %d, cell (%d,%d): owner %d, strength %d, production %d\n",
+        turn, id, x, y, g.Owner[i], g.Strength[i], g.Production[i])
+
+    if g.Owner[i] != g.Id {
+        fmt.Printf("Not our cell, so we give it no orders.\n")
+        return true
+    }
+
+    if war_zones > 0 {
+        fmt.Printf("Phase: war (%d war zones), then attraction\n", war_zones)
+    } else {
+        fmt.Printf("Phase: attraction (no war zones)\n")
+    }
+
+    if g.HasOrders[i] {
+        fmt.Printf("Order: %s, from %s\n", hal.Dir_to_str(g.Moves[i]), g.MovementNotes[i])
+    } else {
+        fmt.Printf("Order: none (stays still)\n")
+    }
+
+    rec := g.TraceFor(i)
+
+    if rec != nil {
+        fmt.Printf("\nTrace from %s:\n", rec.Routine)
+        for _, c := range rec.Candidates {
+            line := fmt.Sprintf("    %-6s %10.0f", hal.Dir_to_str(c.Dir), c.Score)
+            if c.Rejected != "" {
+                line += "    rejected: " + c.Rejected
+            }
+            fmt.Println(line)
+        }
+        for _, reason := range rec.Reasons {
+            fmt.Printf("    %s\n", reason)
+        }
+    } else {
+        fmt.Printf("\nNo routine traced this cell.\n")
+    }
+
+    fmt.Printf("\nNeighbours:\n")
+    fmt.Printf("    %-6s %-9s %5s %8s %10s %10s %8s\n", "dir", "cell", "owner", "strength", "attraction", "allocation", "incoming")
+
+    explain_neighbour_line(g, "still", i)
+    for _, neighbour := range g.Neighbours[i] {
+        explain_neighbour_line(g, hal.Dir_to_str(neighbour.Dir), neighbour.Index)
+    }
+
+    fmt.Printf("\nINTERNAL_MULTIPLIER: strength %d vs production %d * %d = %d -- ",
+        g.Strength[i], g.Production[i], INTERNAL_MULTIPLIER, g.Production[i] * INTERNAL_MULTIPLIER)
+
+    if g.Strength[i] < g.Production[i] * INTERNAL_MULTIPLIER {
+        fmt.Printf("below threshold, Attract() holds it back unless it borders an easy capture\n")
+    } else {
+        fmt.Printf("free to move\n")
+    }
+
+    return true
+}
+
+func explain_neighbour_line(g *hal.Game, dir string, i int) {
+
+    x, y := g.I_to_XY(i)
+
+    attraction := "-"
+    if g.Trace.AttractionMap != nil && g.Trace.AttractionMap[i] != hal.HEATMAP_UNSET {
+        attraction = fmt.Sprintf("%d", g.Trace.AttractionMap[i])
+    }
+
+    fmt.Printf("    %-6s %-9s %5d %8d %10s %10d %8d\n",
+        dir, fmt.Sprintf("(%d,%d)", x, y), g.Owner[i], g.Strength[i], attraction, g.Allocation[i], g.Incoming[i])
+}
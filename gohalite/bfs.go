@@ -1,7 +1,6 @@
 package gohalite
 
 import (
-    "math/rand"
     "sort"
 )
 
@@ -107,7 +106,7 @@ func key_list_bfso(m map[int]BFS_Object, determinist bool) []int {
 
 func shuffle(src []int) {
     dest := make([]int, len(src))
-    perm := rand.Perm(len(src))
+    perm := RNG.Perm(len(src))
     for i, v := range perm {
         dest[v] = src[i]
     }
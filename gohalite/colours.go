@@ -0,0 +1,43 @@
+package gohalite
+
+import (
+    "image/color"
+)
+
+// Colours used by the viewers and renderers. Roughly those of the official visualiser.
+
+var OWNER_COLOURS = []color.RGBA{
+    {128, 128, 128, 255},       // Neutral
+    {255,  96,  64, 255},
+    {64,  160, 255, 255},
+    {96,  224,  64, 255},
+    {240, 208,  32, 255},
+    {200,  96, 255, 255},
+    {48,  224, 208, 255},
+}
+
+func OwnerColour(owner int) color.RGBA {
+    if owner < 0 || owner >= len(OWNER_COLOURS) {
+        return color.RGBA{255, 255, 255, 255}
+    }
+    return OWNER_COLOURS[owner]
+}
+
+func ShadedColour(owner, strength int) color.RGBA {
+
+    // The owner's colour, with brightness from strength. Strength 0 is still visible.
+
+    c := OwnerColour(owner)
+
+    if strength < 0 {
+        strength = 0
+    } else if strength > 255 {
+        strength = 255
+    }
+
+    scale := func(val uint8) uint8 {
+        return uint8(int(val) * (64 + strength * 3 / 4) / 255)
+    }
+
+    return color.RGBA{scale(c.R), scale(c.G), scale(c.B), 255}
+}
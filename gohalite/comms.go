@@ -3,39 +3,141 @@ package gohalite
 import (
     "bufio"
     "fmt"
+    "io"
     "os"
-    "strconv"
-    "strings"
     "time"
 )
 
-var scanner *bufio.Scanner = bufio.NewScanner(os.Stdin)
+// The input is read with a streaming tokenizer rather than a bufio.Scanner, since the
+// latter has a 64 KiB token limit which a big map line (many short RLE runs) can exceed,
+// at which point Scan() just fails. The tokenizer parses integers straight off the reader.
+
+var reader *bufio.Reader = bufio.NewReaderSize(os.Stdin, 64 * 1024)
+var tokenizer = NewIntReader(reader)
+
+type IntReader struct {
+    r           *bufio.Reader
+}
+
+func NewIntReader(r *bufio.Reader) *IntReader {
+    return &IntReader{r}
+}
+
+func (t *IntReader) Next() (int, error) {
+
+    c, err := t.r.ReadByte()
+
+    for err == nil && (c == ' ' || c == '\n' || c == '\r' || c == '\t') {
+        c, err = t.r.ReadByte()
+    }
+
+    if err != nil {
+        return 0, err
+    }
+
+    negative := false
+
+    if c == '-' {
+        negative = true
+        c, err = t.r.ReadByte()
+        if err != nil {
+            return 0, err
+        }
+    }
+
+    if c < '0' || c > '9' {
+        return 0, fmt.Errorf("IntReader: unexpected byte %q", c)
+    }
+
+    result := 0
+
+    for {
+        result = result * 10 + int(c - '0')
+
+        c, err = t.r.ReadByte()
+        if err == io.EOF {
+            break
+        } else if err != nil {
+            return 0, err
+        }
+
+        if c < '0' || c > '9' {
+            t.r.UnreadByte()            // Leave the terminator; in particular, never read past the end of a frame
+            break
+        }
+    }
+
+    if negative {
+        result = -result
+    }
+
+    return result, nil
+}
+
+func (g *Game) exit_on_input_error(err error) {
+
+    // Running out of input is simply the end of the game. Anything else is fatal too,
+    // since we can't know what the engine wanted.
+
+    if err == nil {
+        return
+    }
+    if err == io.EOF {
+        g.LogTimingSummary()                        // The engine closing our input is the only end-of-game signal we get
+        g.SlowTurns.Finish(g)
+        os.Exit(0)
+    }
+    g.LogError(CAT_GENERAL, "Input error on turn %d: %v", g.Turn, err)
+    fmt.Fprintf(os.Stderr, "Input error on turn %d: %v\n", g.Turn, err)
+    os.Exit(1)
+}
 
 func (g *Game) ParseProduction() {
-    scanner.Scan()
-    production_fields := strings.Fields(scanner.Text())
-    for i := 0; i < len(production_fields); i++ {
-        g.Production[i], _ = strconv.Atoi(production_fields[i])
+    g.exit_on_input_error(g.ReadProduction(tokenizer))
+}
+
+func (g *Game) ReadProduction(t *IntReader) error {
+
+    var err error
+
+    for i := 0 ; i < g.Size ; i++ {
+        g.Production[i], err = t.Next()
+        if err != nil {
+            return err
+        }
     }
+    return nil
 }
 
 func (g *Game) ParseMap() {
 
     // See https://halite.io/advanced_writing_sp.php
 
-    scanner.Scan()
-    g.TurnStart = time.Now()        // Do this immediately after .Scan() succeeds
-
-    map_fields := strings.Fields(scanner.Text())
+    g.exit_on_input_error(g.ReadMap(tokenizer))
+}
 
-    field_index := 0
+func (g *Game) ReadMap(t *IntReader) error {
 
     game_index := 0
     for game_index < g.Size {
 
-        num, _ := strconv.Atoi(map_fields[field_index])
-        owner, _ := strconv.Atoi(map_fields[field_index + 1])
-        field_index += 2
+        num, err := t.Next()
+        if err != nil {
+            return err
+        }
+
+        if game_index == 0 {
+            g.TurnStart = time.Now()    // Do this immediately after the frame starts arriving
+        }
+
+        owner, err := t.Next()
+        if err != nil {
+            return err
+        }
+
+        if num < 0 || game_index + num > g.Size {
+            return fmt.Errorf("ReadMap: bad run length %d at cell %d", num, game_index)
+        }
 
         for n := 0 ; n < num ; n++ {
             g.Owner[game_index] = owner
@@ -43,16 +145,25 @@ func (g *Game) ParseMap() {
         }
     }
 
-    game_index = 0
-    for game_index < g.Size {
-        g.Strength[game_index], _ = strconv.Atoi(map_fields[field_index])
-        field_index++
-        game_index++
+    var err error
+
+    for game_index = 0 ; game_index < g.Size ; game_index++ {
+        g.Strength[game_index], err = t.Next()
+        if err != nil {
+            return err
+        }
     }
+
+    return nil
 }
 
 func (g *Game) SendMoves() {
 
+    if Protocol == PROTOCOL_JSON {
+        g.SendMovesJSON()
+        return
+    }
+
     for i := 0 ; i < g.Size ; i++ {
         if g.Owner[i] == g.Id && g.Moves[i] != STILL {
             x, y := g.I_to_XY(i)
@@ -61,3 +172,15 @@ func (g *Game) SendMoves() {
     }
     fmt.Printf("\n")
 }
+
+func SendName(name string) {
+
+    // Tell the engine we're ready.
+
+    if Protocol == PROTOCOL_JSON {
+        SendNameJSON(name)
+        return
+    }
+
+    fmt.Printf("%s\n", name)
+}
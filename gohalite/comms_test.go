@@ -0,0 +1,92 @@
+package gohalite
+
+import (
+    "bufio"
+    "bytes"
+    "strconv"
+    "testing"
+)
+
+// Synthetic frames in the engine's RLE format, with the worst case for line length:
+// every run is a single cell, and the owner changes each time.
+
+func synthetic_frame(width, height int) ([]byte, []int, []int) {
+
+    size := width * height
+    owners := make([]int, size)
+    strengths := make([]int, size)
+
+    var buf bytes.Buffer
+
+    for i := 0 ; i < size ; i++ {
+        owners[i] = i % 3
+        buf.WriteString("1 ")
+        buf.WriteString(strconv.Itoa(owners[i]))
+        buf.WriteString(" ")
+    }
+
+    for i := 0 ; i < size ; i++ {
+        strengths[i] = (i * 7) % 256
+        buf.WriteString(strconv.Itoa(strengths[i]))
+        buf.WriteString(" ")
+    }
+
+    buf.WriteString("\n")
+    return buf.Bytes(), owners, strengths
+}
+
+func sized_game(width, height int) *Game {
+    g := new(Game)
+    g.Width = width
+    g.Height = height
+    g.Size = width * height
+    g.MakeSlices()
+    return g
+}
+
+func TestReadMapLongLine(t *testing.T) {
+
+    // More than bufio.Scanner's 64 KiB token limit, which is what used to break.
+
+    frame, owners, strengths := synthetic_frame(100, 100)
+    if len(frame) <= 64 * 1024 {
+        t.Fatalf("test frame is only %d bytes", len(frame))
+    }
+
+    g := sized_game(100, 100)
+
+    err := g.ReadMap(NewIntReader(bufio.NewReader(bytes.NewReader(frame))))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    for i := 0 ; i < g.Size ; i++ {
+        if g.Owner[i] != owners[i] || g.Strength[i] != strengths[i] {
+            t.Fatalf("cell %d: got owner %d strength %d, wanted %d %d", i, g.Owner[i], g.Strength[i], owners[i], strengths[i])
+        }
+    }
+}
+
+func benchmark_read_map(b *testing.B, width, height int) {
+
+    frame, _, _ := synthetic_frame(width, height)
+    g := sized_game(width, height)
+
+    b.SetBytes(int64(len(frame)))
+    b.ResetTimer()
+
+    for n := 0 ; n < b.N ; n++ {
+        err := g.ReadMap(NewIntReader(bufio.NewReader(bytes.NewReader(frame))))
+        if err != nil {
+            b.Fatal(err)
+        }
+    }
+}
+
+func BenchmarkReadMap50x50(b *testing.B) {
+    benchmark_read_map(b, 50, 50)
+}
+
+func BenchmarkReadMap100x100(b *testing.B) {
+    benchmark_read_map(b, 100, 100)
+}
@@ -0,0 +1,80 @@
+package gohalite
+
+import (
+    "time"
+)
+
+// A Deadline is a point in time by which some piece of work must be finished.
+// Routines can ask it how much budget remains, and can carve sub-budgets out of
+// it for individual phases. A sub-budget never outlives its parent.
+
+const SEND_MARGIN = 100 * time.Millisecond         // Time reserved for sending moves, scheduler hiccups, etc
+const NO_LIMIT = 100 * 365 * 24 * time.Hour         // For work that must never be cut short
+
+type Deadline struct {
+    start       time.Time
+    end         time.Time
+    parent      *Deadline
+}
+
+func NewDeadline(start time.Time, limit time.Duration) *Deadline {
+    return &Deadline{start: start, end: start.Add(limit)}
+}
+
+func (g *Game) TurnDeadline() *Deadline {
+
+    // Less whatever the slow-turn profiler needs for itself (nothing if it's off).
+
+    return NewDeadline(g.TurnStart, TIMEOUT - g.SlowTurns.Margin())
+}
+
+func NoDeadline() *Deadline {
+    return NewDeadline(time.Now(), NO_LIMIT)
+}
+
+func (g *Game) StartupDeadline() *Deadline {
+    return NewDeadline(g.GameStart, INITIAL_TIMEOUT)
+}
+
+func (d *Deadline) End() time.Time {
+    if d.parent != nil && d.parent.End().Before(d.end) {
+        return d.parent.End()
+    }
+    return d.end
+}
+
+func (d *Deadline) Elapsed() time.Duration {
+    return time.Since(d.start)
+}
+
+func (d *Deadline) Remaining() time.Duration {
+    result := time.Until(d.End())
+    if result < 0 {
+        return 0
+    }
+    return result
+}
+
+func (d *Deadline) Expired() bool {
+    return time.Now().After(d.End())
+}
+
+func (d *Deadline) Near() bool {
+
+    // True if we are within the safety margin of the deadline, i.e. optional work should stop now.
+
+    return d.Remaining() <= SEND_MARGIN
+}
+
+func (d *Deadline) Sub(fraction float64) *Deadline {
+
+    // Return a sub-budget covering the given fraction of the (usable) time remaining.
+
+    usable := d.Remaining() - SEND_MARGIN
+    if usable < 0 {
+        usable = 0
+    }
+
+    now := time.Now()
+    return &Deadline{start: now, end: now.Add(time.Duration(float64(usable) * fraction)), parent: d}
+}
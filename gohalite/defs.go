@@ -51,7 +51,11 @@ type Game struct {
     OpeningFlag         bool
     StartLoc            int
     IsSim               bool
+    Untimed             bool            // Offline tools: MakeMoves() never skips work for time
     MovementNotes       []string        // For logging
+    Trace               *Tracer         // Structured decision trace, nil when off
+    Timing              *PhaseTimer     // Per-phase timing telemetry, nil when off
+    SlowTurns           *SlowTurnProfiler   // Profiling of slow turns, nil when off
 }
 
 func (g *Game) Copy() *Game {
@@ -85,6 +89,7 @@ func (g *Game) Copy() *Game {
     result.OpeningFlag = g.OpeningFlag
     result.StartLoc = g.StartLoc
     result.IsSim = g.IsSim
+    result.Untimed = g.Untimed
 
     return result
 }
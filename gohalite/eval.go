@@ -37,9 +37,13 @@ func (g *Game) CountPlayers() int {
 }
 
 func (g *Game) CountFriendlyCells() int {
+    return g.CellsOfPlayer(g.Id)
+}
+
+func (g *Game) CellsOfPlayer(id int) int {
     result := 0
     for i := 0 ; i < g.Size ; i++ {
-        if g.Owner[i] == g.Id {
+        if g.Owner[i] == id {
             result++
         }
     }
@@ -75,11 +79,40 @@ func (g *Game) StrengthOfPlayer(id int) int {
 }
 
 func (g *Game) MyProduction() int {
+    return g.ProductionOfPlayer(g.Id)
+}
+
+func (g *Game) ProductionOfPlayer(id int) int {
     result := 0
     for i := 0 ; i < g.Size ; i++ {
-        if g.Owner[i] == g.Id {
+        if g.Owner[i] == id {
             result += g.Production[i]
         }
     }
     return result
 }
+
+func (g *Game) CapWasteOfPlayer(id int) int {
+
+    // How much strength will the player lose to the 255 cap, given the current moves?
+    // Counts moving pieces, stationary pieces, and production of stationary pieces, as the engine does.
+
+    placements := make([]int, g.Size)
+
+    for i := 0 ; i < g.Size ; i++ {
+        if g.Owner[i] == id {
+            placements[g.Movement_to_I(i, g.Moves[i])] += g.Strength[i]
+            if g.Moves[i] == STILL {
+                placements[i] += g.Production[i]
+            }
+        }
+    }
+
+    result := 0
+    for i := 0 ; i < g.Size ; i++ {
+        if placements[i] > 255 {
+            result += placements[i] - 255
+        }
+    }
+    return result
+}
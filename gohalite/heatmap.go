@@ -0,0 +1,302 @@
+package gohalite
+
+import (
+    "fmt"
+    "image"
+    "image/color"
+    "image/png"
+    "os"
+    "strings"
+)
+
+// Heatmaps of any value map (attraction map, EnemyDistances(), NiceFrontierDistances() etc),
+// as PNG or SVG. The text grids from LogValueMap() are unreadable on big boards. Values in
+// the blank list (and always the -2147483647 used for "unset" in the attraction code) are
+// left white. Our territory is outlined. There's a legend with the range underneath.
+
+const HEATMAP_UNSET = -2147483647
+
+var heatmap_stops = []color.RGBA{               // Roughly viridis
+    {68, 1, 84, 255},
+    {59, 82, 139, 255},
+    {33, 145, 140, 255},
+    {94, 201, 98, 255},
+    {253, 231, 37, 255},
+}
+
+func heat_colour(fraction float64) color.RGBA {
+
+    if fraction <= 0 {
+        return heatmap_stops[0]
+    }
+    if fraction >= 1 {
+        return heatmap_stops[len(heatmap_stops) - 1]
+    }
+
+    pos := fraction * float64(len(heatmap_stops) - 1)
+    n := int(pos)
+    t := pos - float64(n)
+
+    a, b := heatmap_stops[n], heatmap_stops[n + 1]
+    mix := func(x, y uint8) uint8 {
+        return uint8(float64(x) + (float64(y) - float64(x)) * t)
+    }
+    return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+type heatmap_data struct {
+    blank           []bool
+    fractions       []float64
+    min             int
+    max             int
+}
+
+func (g *Game) heatmap_data(value_map []int, blanks []int) heatmap_data {
+
+    var result heatmap_data
+    result.blank = make([]bool, g.Size)
+    result.fractions = make([]float64, g.Size)
+
+    first := true
+
+    for i := 0 ; i < g.Size ; i++ {
+        if value_map[i] == HEATMAP_UNSET {
+            result.blank[i] = true
+        }
+        for _, b := range blanks {
+            if value_map[i] == b {
+                result.blank[i] = true
+            }
+        }
+        if result.blank[i] {
+            continue
+        }
+        if first || value_map[i] < result.min {
+            result.min = value_map[i]
+        }
+        if first || value_map[i] > result.max {
+            result.max = value_map[i]
+        }
+        first = false
+    }
+
+    for i := 0 ; i < g.Size ; i++ {
+        if result.max > result.min {
+            result.fractions[i] = float64(value_map[i] - result.min) / float64(result.max - result.min)
+        }
+    }
+
+    return result
+}
+
+func (g *Game) SaveHeatmap(filename string, value_map []int, cell_size int, blanks ...int) error {
+
+    // The format is chosen by the extension: .svg or (otherwise) .png.
+
+    if len(value_map) != g.Size {
+        return fmt.Errorf("SaveHeatmap: value map has length %d, wanted %d", len(value_map), g.Size)
+    }
+
+    if cell_size < 4 {
+        cell_size = 4
+    }
+
+    data := g.heatmap_data(value_map, blanks)
+
+    outfile, err := os.Create(filename)
+    if err != nil {
+        return err
+    }
+    defer outfile.Close()
+
+    if strings.HasSuffix(filename, ".svg") {
+        _, err = outfile.WriteString(g.heatmap_svg(data, cell_size))
+        return err
+    }
+
+    return png.Encode(outfile, g.heatmap_image(data, cell_size))
+}
+
+func (g *Game) LogHeatmap(tag string, value_map []int, blanks ...int) {
+
+    if g.IsSim || g.Logfile == nil || g.Logfile.enabled == false {
+        return
+    }
+
+    filename := fmt.Sprintf("%s_turn%d_%s.png", strings.TrimSuffix(g.Logfile.outfilename, ".log"), g.Turn, tag)
+
+    err := g.SaveHeatmap(filename, value_map, 12, blanks...)
+    if err != nil {
+        g.Log("Turn %d: couldn't save %s: %v", g.Turn, filename, err)
+        return
+    }
+    g.Log("Turn %d: saved %s", g.Turn, filename)
+}
+
+// Edges of our territory, as (x, y, direction) for every side of an owned cell whose neighbour isn't owned.
+
+func (g *Game) territory_edges() [][3]int {
+    var result [][3]int
+    for i := 0 ; i < g.Size ; i++ {
+        if g.Owner[i] != g.Id {
+            continue
+        }
+        x, y := g.I_to_XY(i)
+        for _, neighbour := range g.Neighbours[i] {
+            if g.Owner[neighbour.Index] != g.Id {
+                result = append(result, [3]int{x, y, neighbour.Dir})
+            }
+        }
+    }
+    return result
+}
+
+// -------------------------------------------------------------------------------------------------------------
+// PNG
+
+func (g *Game) heatmap_image(data heatmap_data, s int) *image.RGBA {
+
+    legend_height := 3 * s + 8              // Bar, then room for the digits
+    width := g.Width * s
+    if width < 120 {
+        width = 120
+    }
+
+    img := image.NewRGBA(image.Rect(0, 0, width, g.Height * s + legend_height))
+
+    white := color.RGBA{255, 255, 255, 255}
+    black := color.RGBA{0, 0, 0, 255}
+
+    fill := func(left, top, right, bottom int, c color.RGBA) {
+        for py := top ; py < bottom ; py++ {
+            for px := left ; px < right ; px++ {
+                img.SetRGBA(px, py, c)
+            }
+        }
+    }
+
+    fill(0, 0, img.Bounds().Dx(), img.Bounds().Dy(), white)
+
+    for i := 0 ; i < g.Size ; i++ {
+        if data.blank[i] {
+            continue
+        }
+        x, y := g.I_to_XY(i)
+        fill(x * s, y * s, x * s + s, y * s + s, heat_colour(data.fractions[i]))
+    }
+
+    for _, edge := range g.territory_edges() {
+        left, top := edge[0] * s, edge[1] * s
+        switch edge[2] {
+        case UP:
+            fill(left, top, left + s, top + 1, black)
+        case DOWN:
+            fill(left, top + s - 1, left + s, top + s, black)
+        case LEFT:
+            fill(left, top, left + 1, top + s, black)
+        case RIGHT:
+            fill(left + s - 1, top, left + s, top + s, black)
+        }
+    }
+
+    // Legend: gradient bar with the min and max values written under its ends.
+
+    bar_top := g.Height * s + s / 2
+    bar_left := 4
+    bar_right := width - 4
+
+    for px := bar_left ; px < bar_right ; px++ {
+        c := heat_colour(float64(px - bar_left) / float64(bar_right - bar_left - 1))
+        fill(px, bar_top, px + 1, bar_top + s, c)
+    }
+
+    text_top := bar_top + s + 2
+    draw_number(img, bar_left, text_top, data.min, black)
+    max_text := fmt.Sprintf("%d", data.max)
+    draw_number(img, bar_right - len(max_text) * 4, text_top, data.max, black)
+
+    return img
+}
+
+// A tiny 3x5 font, enough to write the legend's numbers without any font package.
+
+var tiny_digits = map[rune][5]string{
+    '0': {"###", "# #", "# #", "# #", "###"},
+    '1': {" # ", "## ", " # ", " # ", "###"},
+    '2': {"###", "  #", "###", "#  ", "###"},
+    '3': {"###", "  #", " ##", "  #", "###"},
+    '4': {"# #", "# #", "###", "  #", "  #"},
+    '5': {"###", "#  ", "###", "  #", "###"},
+    '6': {"###", "#  ", "###", "# #", "###"},
+    '7': {"###", "  #", "  #", "  #", "  #"},
+    '8': {"###", "# #", "###", "# #", "###"},
+    '9': {"###", "# #", "###", "  #", "###"},
+    '-': {"   ", "   ", "###", "   ", "   "},
+}
+
+func draw_number(img *image.RGBA, left, top, val int, c color.RGBA) {
+    for n, r := range fmt.Sprintf("%d", val) {
+        glyph := tiny_digits[r]
+        for row := 0 ; row < 5 ; row++ {
+            for col := 0 ; col < 3 ; col++ {
+                if glyph[row][col] == '#' {
+                    img.SetRGBA(left + n * 4 + col, top + row, c)
+                }
+            }
+        }
+    }
+}
+
+// -------------------------------------------------------------------------------------------------------------
+// SVG
+
+func (g *Game) heatmap_svg(data heatmap_data, s int) string {
+
+    var sb strings.Builder
+
+    width := g.Width * s
+    height := g.Height * s + 4 * s
+
+    fmt.Fprintf(&sb, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\">\n", width, height)
+    fmt.Fprintf(&sb, "<rect width=\"%d\" height=\"%d\" fill=\"white\"/>\n", width, height)
+
+    for i := 0 ; i < g.Size ; i++ {
+        if data.blank[i] {
+            continue
+        }
+        x, y := g.I_to_XY(i)
+        c := heat_colour(data.fractions[i])
+        fmt.Fprintf(&sb, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"#%02x%02x%02x\"/>\n", x * s, y * s, s, s, c.R, c.G, c.B)
+    }
+
+    for _, edge := range g.territory_edges() {
+        left, top := edge[0] * s, edge[1] * s
+        x1, y1, x2, y2 := left, top, left + s, top
+        switch edge[2] {
+        case DOWN:
+            y1, y2 = top + s, top + s
+        case LEFT:
+            x2, y2 = left, top + s
+        case RIGHT:
+            x1, x2, y2 = left + s, left + s, top + s
+        }
+        fmt.Fprintf(&sb, "<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"black\" stroke-width=\"2\"/>\n", x1, y1, x2, y2)
+    }
+
+    // Legend...
+
+    bar_top := g.Height * s + s / 2
+
+    sb.WriteString("<defs><linearGradient id=\"scale\">\n")
+    for n, c := range heatmap_stops {
+        fmt.Fprintf(&sb, "<stop offset=\"%d%%\" stop-color=\"#%02x%02x%02x\"/>\n", n * 100 / (len(heatmap_stops) - 1), c.R, c.G, c.B)
+    }
+    sb.WriteString("</linearGradient></defs>\n")
+
+    fmt.Fprintf(&sb, "<rect x=\"0\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"url(#scale)\"/>\n", bar_top, width, s)
+    fmt.Fprintf(&sb, "<text x=\"0\" y=\"%d\" font-family=\"monospace\" font-size=\"%d\">%d</text>\n", bar_top + 2 * s + s / 2, s, data.min)
+    fmt.Fprintf(&sb, "<text x=\"%d\" y=\"%d\" font-family=\"monospace\" font-size=\"%d\" text-anchor=\"end\">%d</text>\n", width, bar_top + 2 * s + s / 2, s, data.max)
+
+    sb.WriteString("</svg>\n")
+    return sb.String()
+}
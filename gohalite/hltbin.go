@@ -0,0 +1,564 @@
+package gohalite
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/binary"
+    "fmt"
+    "io"
+    "os"
+)
+
+// Compact binary replay format, for training-data pipelines and the like. Lossless w.r.t. HLT.
+//
+//      "HLTB"                  magic
+//      uvarint                 format version
+//      uvarint x 7             HLT version, width, height, num_players, num_frames, num_moves, keyframe interval
+//      names                   uvarint count, then for each: uvarint length, bytes
+//      productions             width * height uvarints, stored once
+//      offset table            num_frames + num_moves little-endian uint64s, relative to the body
+//      body                    frame records, then move records
+//
+// A frame record is either a keyframe (byte 0, then width * height owner bytes, then as many
+// strength bytes) or a delta against the previous frame (byte 1, uvarint change count, then for
+// each change: uvarint index gap, owner byte, strength byte). Every BINARY_KEYFRAME_INTERVAL-th
+// frame is a keyframe, so reading any turn needs at most that many records.
+//
+// A move record is the move of every cell, packed 3 bits per cell (cell i in bits 3i to 3i+2).
+//
+// Cells are in the usual y,x order, i.e. index y * width + x.
+
+const (
+    BINARY_MAGIC = "HLTB"
+    BINARY_FORMAT_VERSION = 1
+    BINARY_KEYFRAME_INTERVAL = 32
+)
+
+func (h *HLT) SaveBinary(filename string) error {
+
+    outfile, err := os.Create(filename)
+    if err != nil {
+        return err
+    }
+    defer outfile.Close()
+
+    return h.WriteBinary(outfile)
+}
+
+func (h *HLT) WriteBinary(w io.Writer) error {
+
+    size := h.Width * h.Height
+
+    if len(h.Productions) != h.Height {
+        return fmt.Errorf("WriteBinary: %d production rows for height %d", len(h.Productions), h.Height)
+    }
+
+    // The body goes into a buffer first, so we know the offsets before writing the table...
+
+    var body bytes.Buffer
+    var offsets []uint64
+
+    var previous []Site
+
+    for n, frame := range h.Frames {
+
+        current, err := flatten_frame(frame, h.Width, h.Height)
+        if err != nil {
+            return fmt.Errorf("WriteBinary: frame %d: %v", n, err)
+        }
+
+        offsets = append(offsets, uint64(body.Len()))
+
+        if n % BINARY_KEYFRAME_INTERVAL == 0 {
+            body.WriteByte(0)
+            for i := 0 ; i < size ; i++ {
+                body.WriteByte(byte(current[i].Owner))
+            }
+            for i := 0 ; i < size ; i++ {
+                body.WriteByte(byte(current[i].Strength))
+            }
+        } else {
+            var changes []int
+            for i := 0 ; i < size ; i++ {
+                if current[i] != previous[i] {
+                    changes = append(changes, i)
+                }
+            }
+            body.WriteByte(1)
+            write_uvarint(&body, len(changes))
+            last := 0
+            for _, i := range changes {
+                write_uvarint(&body, i - last)
+                body.WriteByte(byte(current[i].Owner))
+                body.WriteByte(byte(current[i].Strength))
+                last = i
+            }
+        }
+
+        previous = current
+    }
+
+    for n, moves := range h.Moves {
+
+        if len(moves) != h.Height {
+            return fmt.Errorf("WriteBinary: move grid %d has %d rows", n, len(moves))
+        }
+
+        offsets = append(offsets, uint64(body.Len()))
+
+        packed := make([]byte, (size * 3 + 7) / 8)
+
+        for y := 0 ; y < h.Height ; y++ {
+            if len(moves[y]) != h.Width {
+                return fmt.Errorf("WriteBinary: move grid %d row %d has length %d", n, y, len(moves[y]))
+            }
+            for x := 0 ; x < h.Width ; x++ {
+                move := moves[y][x]
+                if move < STILL || move > WEST {
+                    return fmt.Errorf("WriteBinary: move grid %d has bad move %d at [%d,%d]", n, move, x, y)
+                }
+                set_bits(packed, (y * h.Width + x) * 3, move)
+            }
+        }
+
+        body.Write(packed)
+    }
+
+    // Now the header...
+
+    var header bytes.Buffer
+
+    header.WriteString(BINARY_MAGIC)
+    write_uvarint(&header, BINARY_FORMAT_VERSION)
+
+    for _, val := range []int{h.Version, h.Width, h.Height, h.NumPlayers, len(h.Frames), len(h.Moves), BINARY_KEYFRAME_INTERVAL} {
+        write_uvarint(&header, val)
+    }
+
+    write_uvarint(&header, len(h.PlayerNames))
+    for _, name := range h.PlayerNames {
+        write_uvarint(&header, len(name))
+        header.WriteString(name)
+    }
+
+    for y := 0 ; y < h.Height ; y++ {
+        if len(h.Productions[y]) != h.Width {
+            return fmt.Errorf("WriteBinary: production row %d has length %d", y, len(h.Productions[y]))
+        }
+        for x := 0 ; x < h.Width ; x++ {
+            write_uvarint(&header, h.Productions[y][x])
+        }
+    }
+
+    for _, offset := range offsets {
+        binary.Write(&header, binary.LittleEndian, offset)
+    }
+
+    _, err := w.Write(header.Bytes())
+    if err != nil {
+        return err
+    }
+
+    _, err = w.Write(body.Bytes())
+    return err
+}
+
+func flatten_frame(frame [][]Site, width, height int) ([]Site, error) {
+
+    if len(frame) != height {
+        return nil, fmt.Errorf("%d rows for height %d", len(frame), height)
+    }
+
+    result := make([]Site, 0, width * height)
+
+    for y := 0 ; y < height ; y++ {
+        if len(frame[y]) != width {
+            return nil, fmt.Errorf("row %d has length %d", y, len(frame[y]))
+        }
+        for x := 0 ; x < width ; x++ {
+            site := frame[y][x]
+            if site.Owner < 0 || site.Owner > 255 || site.Strength < 0 || site.Strength > 255 {
+                return nil, fmt.Errorf("site [%d,%d] out of range: %v", x, y, site)
+            }
+            result = append(result, site)
+        }
+    }
+
+    return result, nil
+}
+
+func write_uvarint(buf *bytes.Buffer, val int) {
+    var tmp [binary.MaxVarintLen64]byte
+    n := binary.PutUvarint(tmp[:], uint64(val))
+    buf.Write(tmp[:n])
+}
+
+func set_bits(packed []byte, bit int, val int) {
+    for b := 0 ; b < 3 ; b++ {
+        if val & (1 << uint(b)) != 0 {
+            packed[(bit + b) / 8] |= 1 << uint((bit + b) % 8)
+        }
+    }
+}
+
+func get_bits(packed []byte, bit int) int {
+    result := 0
+    for b := 0 ; b < 3 ; b++ {
+        if packed[(bit + b) / 8] & (1 << uint((bit + b) % 8)) != 0 {
+            result |= 1 << uint(b)
+        }
+    }
+    return result
+}
+
+// -------------------------------------------------------------------------------------------------------------
+// Reading. A BinaryReplay gives random access to any turn.
+
+type BinaryReplay struct {
+    Header          *HLT                // Frames and Moves are left nil
+    file            io.ReaderAt
+    closer          io.Closer
+    file_size       int64
+    body_start      int64
+    frame_offsets   []int64
+    move_offsets    []int64
+    interval        int
+}
+
+type counting_reader struct {
+    r               *bufio.Reader
+    count           int64
+}
+
+func (c *counting_reader) ReadByte() (byte, error) {
+    b, err := c.r.ReadByte()
+    if err == nil {
+        c.count++
+    }
+    return b, err
+}
+
+func (c *counting_reader) Read(p []byte) (int, error) {
+    n, err := io.ReadFull(c.r, p)
+    c.count += int64(n)
+    return n, err
+}
+
+func (c *counting_reader) uvarint() (int, error) {
+    val, err := binary.ReadUvarint(c)
+    return int(val), err
+}
+
+func OpenBinaryReplay(filename string) (*BinaryReplay, error) {
+
+    infile, err := os.Open(filename)
+    if err != nil {
+        return nil, err
+    }
+
+    info, err := infile.Stat()
+    if err != nil {
+        infile.Close()
+        return nil, err
+    }
+
+    r, err := NewBinaryReplay(infile, info.Size())
+    if err != nil {
+        infile.Close()
+        return nil, err
+    }
+
+    r.closer = infile
+    return r, nil
+}
+
+func NewBinaryReplay(file io.ReaderAt, file_size int64) (*BinaryReplay, error) {
+
+    // The size is needed so that lengths read from a corrupt file can be sanity checked
+    // before anything is allocated for them.
+
+    c := &counting_reader{r: bufio.NewReader(io.NewSectionReader(file, 0, file_size))}
+
+    magic := make([]byte, len(BINARY_MAGIC))
+    _, err := c.Read(magic)
+    if err != nil || string(magic) != BINARY_MAGIC {
+        return nil, fmt.Errorf("NewBinaryReplay: not a binary replay")
+    }
+
+    format_version, err := c.uvarint()
+    if err != nil {
+        return nil, err
+    }
+    if format_version != BINARY_FORMAT_VERSION {
+        return nil, fmt.Errorf("NewBinaryReplay: format version %d, wanted %d", format_version, BINARY_FORMAT_VERSION)
+    }
+
+    var vals [7]int
+    for n := range vals {
+        vals[n], err = c.uvarint()
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    r := new(BinaryReplay)
+    r.file = file
+    r.file_size = file_size
+    r.Header = new(HLT)
+    r.Header.Version = vals[0]
+    r.Header.Width = vals[1]
+    r.Header.Height = vals[2]
+    r.Header.NumPlayers = vals[3]
+    r.Header.NumFrames = vals[4]
+    num_moves := vals[5]
+    r.interval = vals[6]
+
+    if r.interval <= 0 {
+        return nil, fmt.Errorf("NewBinaryReplay: bad keyframe interval %d", r.interval)
+    }
+
+    // Every production takes at least a byte, and every offset 8, so a header promising more
+    // than the file holds is corrupt. Checking now avoids allocating for it.
+
+    w, h := int64(r.Header.Width), int64(r.Header.Height)
+    if w <= 0 || h <= 0 || w > file_size || h > file_size || w * h > file_size {
+        return nil, fmt.Errorf("NewBinaryReplay: bad map size %dx%d", r.Header.Width, r.Header.Height)
+    }
+    if r.Header.NumFrames < 0 || num_moves < 0 || int64(r.Header.NumFrames) > file_size / 8 || int64(num_moves) > file_size / 8 {
+        return nil, fmt.Errorf("NewBinaryReplay: bad record counts %d and %d", r.Header.NumFrames, num_moves)
+    }
+
+    name_count, err := c.uvarint()
+    if err != nil {
+        return nil, err
+    }
+    for n := 0 ; n < name_count ; n++ {
+        length, err := c.uvarint()
+        if err != nil {
+            return nil, err
+        }
+        if length < 0 || int64(length) > file_size - c.count {
+            return nil, fmt.Errorf("NewBinaryReplay: name length %d is more than the rest of the file", length)
+        }
+        name := make([]byte, length)
+        _, err = c.Read(name)
+        if err != nil {
+            return nil, err
+        }
+        r.Header.PlayerNames = append(r.Header.PlayerNames, string(name))
+    }
+
+    r.Header.Productions = make([][]int, r.Header.Height)
+    for y := 0 ; y < r.Header.Height ; y++ {
+        r.Header.Productions[y] = make([]int, r.Header.Width)
+        for x := 0 ; x < r.Header.Width ; x++ {
+            r.Header.Productions[y][x], err = c.uvarint()
+            if err != nil {
+                return nil, err
+            }
+        }
+    }
+
+    var offsets []uint64
+
+    for n := 0 ; n < r.Header.NumFrames + num_moves ; n++ {
+        var offset uint64
+        err = binary.Read(c, binary.LittleEndian, &offset)
+        if err != nil {
+            return nil, err
+        }
+        offsets = append(offsets, offset)
+    }
+
+    r.body_start = c.count
+
+    for n, offset := range offsets {
+        if offset >= uint64(file_size - r.body_start) {
+            return nil, fmt.Errorf("NewBinaryReplay: record %d's offset %d is past the end of the file", n, offset)
+        }
+        if n < r.Header.NumFrames {
+            r.frame_offsets = append(r.frame_offsets, int64(offset))
+        } else {
+            r.move_offsets = append(r.move_offsets, int64(offset))
+        }
+    }
+
+    return r, nil
+}
+
+func (r *BinaryReplay) Close() error {
+    if r.closer != nil {
+        return r.closer.Close()
+    }
+    return nil
+}
+
+func (r *BinaryReplay) record_reader(offset int64) *counting_reader {
+    return &counting_reader{r: bufio.NewReader(io.NewSectionReader(r.file, r.body_start + offset, r.file_size - r.body_start - offset))}
+}
+
+func (r *BinaryReplay) Frame(turn int) ([][]Site, error) {
+
+    if turn < 0 || turn >= len(r.frame_offsets) {
+        return nil, fmt.Errorf("Frame: wanted turn %d but replay only had %d frames", turn, len(r.frame_offsets))
+    }
+
+    sites := make([]Site, r.Header.Width * r.Header.Height)
+
+    // Start at the nearest keyframe at or before the turn, then apply deltas...
+
+    for n := turn - turn % r.interval ; n <= turn ; n++ {
+        err := r.apply_record(sites, n)
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    return r.unflatten(sites), nil
+}
+
+func (r *BinaryReplay) apply_record(sites []Site, n int) error {
+
+    size := len(sites)
+
+    c := r.record_reader(r.frame_offsets[n])
+
+    kind, err := c.ReadByte()
+    if err != nil {
+        return err
+    }
+
+    if kind == 0 {
+
+        raw := make([]byte, size * 2)
+        _, err = c.Read(raw)
+        if err != nil {
+            return err
+        }
+        for i := 0 ; i < size ; i++ {
+            sites[i] = Site{int(raw[i]), int(raw[size + i])}
+        }
+
+    } else if kind == 1 {
+
+        if n % r.interval == 0 {
+            return fmt.Errorf("apply_record: turn %d should be a keyframe", n)
+        }
+        count, err := c.uvarint()
+        if err != nil {
+            return err
+        }
+        i := 0
+        for k := 0 ; k < count ; k++ {
+            gap, err := c.uvarint()
+            if err != nil {
+                return err
+            }
+            i += gap
+            if i >= size {
+                return fmt.Errorf("apply_record: delta index %d out of range", i)
+            }
+            owner, err := c.ReadByte()
+            if err != nil {
+                return err
+            }
+            strength, err := c.ReadByte()
+            if err != nil {
+                return err
+            }
+            sites[i] = Site{int(owner), int(strength)}
+        }
+
+    } else {
+        return fmt.Errorf("apply_record: bad record kind %d at turn %d", kind, n)
+    }
+
+    return nil
+}
+
+func (r *BinaryReplay) unflatten(sites []Site) [][]Site {
+    result := make([][]Site, r.Header.Height)
+    for y := 0 ; y < r.Header.Height ; y++ {
+        result[y] = make([]Site, r.Header.Width)
+        copy(result[y], sites[y * r.Header.Width : (y + 1) * r.Header.Width])
+    }
+    return result
+}
+
+func (r *BinaryReplay) Moves(turn int) ([][]int, error) {
+
+    if turn < 0 || turn >= len(r.move_offsets) {
+        return nil, fmt.Errorf("Moves: wanted turn %d but replay only had %d movelists", turn, len(r.move_offsets))
+    }
+
+    size := r.Header.Width * r.Header.Height
+
+    packed := make([]byte, (size * 3 + 7) / 8)
+    _, err := r.record_reader(r.move_offsets[turn]).Read(packed)
+    if err != nil {
+        return nil, err
+    }
+
+    result := make([][]int, r.Header.Height)
+    for y := 0 ; y < r.Header.Height ; y++ {
+        result[y] = make([]int, r.Header.Width)
+        for x := 0 ; x < r.Header.Width ; x++ {
+            result[y][x] = get_bits(packed, (y * r.Header.Width + x) * 3)
+        }
+    }
+
+    return result, nil
+}
+
+func (r *BinaryReplay) MoveCount() int {
+    return len(r.move_offsets)
+}
+
+func (r *BinaryReplay) ToHLT() (*HLT, error) {
+
+    h := new(HLT)
+    *h = *r.Header
+
+    // Sequential, so each record is applied once...
+
+    sites := make([]Site, h.Width * h.Height)
+
+    for n := 0 ; n < len(r.frame_offsets) ; n++ {
+        err := r.apply_record(sites, n)
+        if err != nil {
+            return nil, err
+        }
+        h.Frames = append(h.Frames, r.unflatten(sites))
+    }
+
+    for n := 0 ; n < len(r.move_offsets) ; n++ {
+        moves, err := r.Moves(n)
+        if err != nil {
+            return nil, err
+        }
+        h.Moves = append(h.Moves, moves)
+    }
+
+    return h, nil
+}
+
+func LoadBinary(filename string) (*HLT, error) {
+
+    r, err := OpenBinaryReplay(filename)
+    if err != nil {
+        return nil, err
+    }
+    defer r.Close()
+
+    return r.ToHLT()
+}
+
+func (g *Game) SetBoardFromBinary(r *BinaryReplay, turn int, id int) error {
+
+    frame, err := r.Frame(turn)
+    if err != nil {
+        return fmt.Errorf("SetBoardFromBinary: %v", err)
+    }
+
+    return g.SetBoardFromFrame(r.Header, frame, turn, id)
+}
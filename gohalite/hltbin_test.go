@@ -0,0 +1,132 @@
+package gohalite
+
+import (
+    "bytes"
+    "encoding/binary"
+    "fmt"
+    "math/rand"
+    "reflect"
+    "testing"
+)
+
+// A small replay that still has more frames than BINARY_KEYFRAME_INTERVAL, so that both kinds
+// of frame record get written. Each frame changes a few cells of the one before.
+
+func synthetic_hlt(width, height, frames int) *HLT {
+
+    r := rand.New(rand.NewSource(1))
+
+    h := &HLT{Version: HLT_VERSION, Width: width, Height: height, NumPlayers: 2, PlayerNames: []string{"alice", "bob"}}
+
+    for y := 0 ; y < height ; y++ {
+        h.Productions = append(h.Productions, make([]int, width))
+        for x := 0 ; x < width ; x++ {
+            h.Productions[y][x] = r.Intn(16)
+        }
+    }
+
+    frame := make([][]Site, height)
+    for y := range frame {
+        frame[y] = make([]Site, width)
+    }
+
+    for n := 0 ; n < frames ; n++ {
+
+        next := make([][]Site, height)
+        for y := range next {
+            next[y] = append([]Site(nil), frame[y]...)
+        }
+        for k := 0 ; k < 3 ; k++ {
+            next[r.Intn(height)][r.Intn(width)] = Site{r.Intn(3), r.Intn(256)}
+        }
+        h.Frames = append(h.Frames, next)
+        frame = next
+
+        if n < frames - 1 {
+            moves := make([][]int, height)
+            for y := range moves {
+                moves[y] = make([]int, width)
+                for x := range moves[y] {
+                    moves[y][x] = r.Intn(5)
+                }
+            }
+            h.Moves = append(h.Moves, moves)
+        }
+    }
+
+    h.NumFrames = len(h.Frames)
+    return h
+}
+
+func decode_binary(data []byte) (*HLT, error) {
+    r, err := NewBinaryReplay(bytes.NewReader(data), int64(len(data)))
+    if err != nil {
+        return nil, err
+    }
+    return r.ToHLT()
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+
+    h := synthetic_hlt(7, 5, BINARY_KEYFRAME_INTERVAL + 8)
+
+    var buf bytes.Buffer
+    err := h.WriteBinary(&buf)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    result, err := decode_binary(buf.Bytes())
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if reflect.DeepEqual(h, result) == false {
+        t.Fatalf("replay changed in the round trip")
+    }
+}
+
+func TestBinaryCorruptInput(t *testing.T) {
+
+    // Every truncation, and a few different bad values at every byte. Errors are expected;
+    // panics are not.
+
+    h := synthetic_hlt(7, 5, BINARY_KEYFRAME_INTERVAL + 8)
+
+    var buf bytes.Buffer
+    err := h.WriteBinary(&buf)
+    if err != nil {
+        t.Fatal(err)
+    }
+    good := buf.Bytes()
+
+    try := func(desc string, data []byte) {
+        defer func() {
+            if r := recover(); r != nil {
+                t.Fatalf("%s: panic: %v", desc, r)
+            }
+        }()
+        decode_binary(data)
+    }
+
+    // A header claiming a 2^63-wide map, and no player names...
+
+    huge := []byte(BINARY_MAGIC)
+    for _, val := range []uint64{BINARY_FORMAT_VERSION, HLT_VERSION, 1 << 63, 1, 2, 1, 0, BINARY_KEYFRAME_INTERVAL, 0} {
+        var tmp [binary.MaxVarintLen64]byte
+        huge = append(huge, tmp[:binary.PutUvarint(tmp[:], val)]...)
+    }
+    try("huge width", huge)
+
+    for n := 0 ; n < len(good) ; n++ {
+        try(fmt.Sprintf("truncated to %d bytes", n), good[:n])
+    }
+
+    for n := 0 ; n < len(good) ; n++ {
+        for _, val := range []byte{0x00, 0x7f, 0xff, good[n] ^ 0x80} {
+            data := append([]byte(nil), good...)
+            data[n] = val
+            try(fmt.Sprintf("byte %d set to %#x", n, val), data)
+        }
+    }
+}
@@ -0,0 +1,170 @@
+package gohalite
+
+import (
+    "fmt"
+)
+
+// Operations for cutting replays down, e.g. to build test fixtures. Each returns a new HLT
+// (sharing nothing with the original) which passes Validate() if the original did.
+
+func (h *HLT) header_copy() *HLT {
+    result := new(HLT)
+    *result = *h
+    result.PlayerNames = append([]string(nil), h.PlayerNames...)
+    result.Productions = copy_grid(h.Productions)
+    result.Frames = nil
+    result.Moves = nil
+    return result
+}
+
+func copy_grid(grid [][]int) [][]int {
+    result := make([][]int, len(grid))
+    for y := range grid {
+        result[y] = append([]int(nil), grid[y]...)
+    }
+    return result
+}
+
+func copy_frame(frame [][]Site) [][]Site {
+    result := make([][]Site, len(frame))
+    for y := range frame {
+        result[y] = append([]Site(nil), frame[y]...)
+    }
+    return result
+}
+
+func (h *HLT) Slice(first, last int) (*HLT, error) {
+
+    // Keep frames first to last inclusive, plus the moves between them.
+
+    if first < 0 || last >= len(h.Frames) || first > last {
+        return nil, fmt.Errorf("Slice: bad range %d-%d for %d frames", first, last, len(h.Frames))
+    }
+
+    result := h.header_copy()
+
+    for turn := first ; turn <= last ; turn++ {
+        result.Frames = append(result.Frames, copy_frame(h.Frames[turn]))
+        if turn < last && turn < len(h.Moves) {
+            result.Moves = append(result.Moves, copy_grid(h.Moves[turn]))
+        }
+    }
+
+    result.NumFrames = len(result.Frames)
+    return result, nil
+}
+
+func (h *HLT) Crop(left, top, width, height int) (*HLT, error) {
+
+    // Cut out a rectangle of the torus; left and top can be anything, the region wraps.
+    // The result is itself a (smaller) torus, so pieces that moved across the edge of
+    // the rectangle will appear to wrap round. Fine for fixtures, not for replaying.
+
+    if width <= 0 || height <= 0 || width > h.Width || height > h.Height {
+        return nil, fmt.Errorf("Crop: bad size %dx%d for %dx%d map", width, height, h.Width, h.Height)
+    }
+
+    wrap := func(val, max int) int {
+        return ((val % max) + max) % max
+    }
+
+    result := h.header_copy()
+    result.Width = width
+    result.Height = height
+
+    result.Productions = make([][]int, height)
+    for y := 0 ; y < height ; y++ {
+        result.Productions[y] = make([]int, width)
+        for x := 0 ; x < width ; x++ {
+            result.Productions[y][x] = h.Productions[wrap(top + y, h.Height)][wrap(left + x, h.Width)]
+        }
+    }
+
+    for _, frame := range h.Frames {
+        cropped := make([][]Site, height)
+        for y := 0 ; y < height ; y++ {
+            cropped[y] = make([]Site, width)
+            for x := 0 ; x < width ; x++ {
+                cropped[y][x] = frame[wrap(top + y, h.Height)][wrap(left + x, h.Width)]
+            }
+        }
+        result.Frames = append(result.Frames, cropped)
+    }
+
+    for _, moves := range h.Moves {
+        cropped := make([][]int, height)
+        for y := 0 ; y < height ; y++ {
+            cropped[y] = make([]int, width)
+            for x := 0 ; x < width ; x++ {
+                cropped[y][x] = moves[wrap(top + y, h.Height)][wrap(left + x, h.Width)]
+            }
+        }
+        result.Moves = append(result.Moves, cropped)
+    }
+
+    result.NumFrames = len(result.Frames)
+    return result, nil
+}
+
+func (h *HLT) RemapPlayers(mapping []int) (*HLT, error) {
+
+    // mapping[old_id] == new_id, for ids 1 to NumPlayers. Must be a permutation.
+    // Neutral (0) always stays 0.
+
+    if len(mapping) != h.NumPlayers + 1 || mapping[0] != 0 {
+        return nil, fmt.Errorf("RemapPlayers: mapping must have length %d and map 0 to 0", h.NumPlayers + 1)
+    }
+
+    seen := make(map[int]bool)
+    for _, id := range mapping {
+        if id < 0 || id > h.NumPlayers || seen[id] {
+            return nil, fmt.Errorf("RemapPlayers: mapping %v is not a permutation", mapping)
+        }
+        seen[id] = true
+    }
+
+    result := h.header_copy()
+
+    if len(h.PlayerNames) == h.NumPlayers {
+        for old_id := 1 ; old_id <= h.NumPlayers ; old_id++ {
+            result.PlayerNames[mapping[old_id] - 1] = h.PlayerNames[old_id - 1]
+        }
+    }
+
+    for _, frame := range h.Frames {
+        remapped := copy_frame(frame)
+        for y := range remapped {
+            for x := range remapped[y] {
+                owner := remapped[y][x].Owner
+                if owner < 0 || owner > h.NumPlayers {
+                    return nil, fmt.Errorf("RemapPlayers: owner %d out of range", owner)
+                }
+                remapped[y][x].Owner = mapping[owner]
+            }
+        }
+        result.Frames = append(result.Frames, remapped)
+    }
+
+    for _, moves := range h.Moves {
+        result.Moves = append(result.Moves, copy_grid(moves))
+    }
+
+    return result, nil
+}
+
+func (h *HLT) MakePlayerOne(id int) (*HLT, error) {
+
+    // Swap the given player with player 1, so "our" bot is always player 1.
+
+    if id < 1 || id > h.NumPlayers {
+        return nil, fmt.Errorf("MakePlayerOne: no player %d", id)
+    }
+
+    mapping := make([]int, h.NumPlayers + 1)
+    for n := range mapping {
+        mapping[n] = n
+    }
+    mapping[1], mapping[id] = id, 1
+
+    return h.RemapPlayers(mapping)
+}
@@ -0,0 +1,217 @@
+package gohalite
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+)
+
+// Streaming HLT decoder. LoadHLT() holds every frame in memory at once, which is a lot for a
+// long 6 player game on a big map. The HLTStream instead reads the header fields (everything
+// but the frames and moves) up front, then yields one turn at a time:
+//
+//      stream, err := OpenHLTStream("foo.hlt.gz")
+//      defer stream.Close()
+//      for {
+//          frame, moves, err := stream.Next()
+//          if err == io.EOF { break }
+//          ...
+//      }
+//
+// moves is nil for the final frame. The official engine writes "frames" before "moves", so to
+// interleave them the stream keeps a second reader on the file, positioned at the moves. The
+// header fields must come before the frames, as they do in files from the engine and SaveHLT().
+
+type HLTStream struct {
+    Header          *HLT                // Frames and Moves are left nil
+    Turn            int                 // Turn of the frame most recently returned by Next()
+    frames          *json.Decoder
+    moves           *json.Decoder
+    closers         []io.Closer
+    frames_left     bool
+    moves_left      bool
+}
+
+func OpenHLTStream(filename string) (*HLTStream, error) {
+
+    s := new(HLTStream)
+    s.Header = new(HLT)
+    s.Turn = -1
+
+    frames_reader, err := open_hlt(filename)
+    if err != nil {
+        return nil, err
+    }
+    s.closers = append(s.closers, frames_reader)
+
+    moves_reader, err := open_hlt(filename)
+    if err != nil {
+        s.Close()
+        return nil, err
+    }
+    s.closers = append(s.closers, moves_reader)
+
+    s.frames = json.NewDecoder(frames_reader)
+    s.moves = json.NewDecoder(moves_reader)
+
+    s.frames_left, err = seek_hlt_key(s.frames, "frames", s.Header)
+    if err != nil {
+        s.Close()
+        return nil, err
+    }
+
+    s.moves_left, err = seek_hlt_key(s.moves, "moves", nil)
+    if err != nil {
+        s.Close()
+        return nil, err
+    }
+
+    return s, nil
+}
+
+func seek_hlt_key(decoder *json.Decoder, key string, header *HLT) (bool, error) {
+
+    // Advance the decoder to just inside the array stored under key. Returns false if the key
+    // wasn't found (or held null). If header is not nil, other top-level fields are decoded into it.
+
+    tok, err := decoder.Token()
+    if err != nil {
+        return false, err
+    }
+    if delim, ok := tok.(json.Delim); ok == false || delim != '{' {
+        return false, fmt.Errorf("seek_hlt_key: file is not a JSON object")
+    }
+
+    for decoder.More() {
+
+        tok, err = decoder.Token()
+        if err != nil {
+            return false, err
+        }
+
+        name, ok := tok.(string)
+        if ok == false {
+            return false, fmt.Errorf("seek_hlt_key: expected a key, got %v", tok)
+        }
+
+        if name == key {
+            tok, err = decoder.Token()
+            if err != nil {
+                return false, err
+            }
+            if tok == nil {
+                return false, nil
+            }
+            if delim, ok := tok.(json.Delim); ok == false || delim != '[' {
+                return false, fmt.Errorf("seek_hlt_key: %s is not an array", key)
+            }
+            return true, nil
+        }
+
+        if header == nil {
+            err = skip_json_value(decoder)      // Token by token, so skipping the frames doesn't load them
+            if err != nil {
+                return false, err
+            }
+            continue
+        }
+
+        var value json.RawMessage
+        err = decoder.Decode(&value)
+        if err != nil {
+            return false, err
+        }
+
+        err = header.set_field(name, value)
+        if err != nil {
+            return false, err
+        }
+    }
+
+    return false, nil
+}
+
+func skip_json_value(decoder *json.Decoder) error {
+
+    depth := 0
+
+    for {
+        tok, err := decoder.Token()
+        if err != nil {
+            return err
+        }
+
+        if delim, ok := tok.(json.Delim); ok {
+            if delim == '[' || delim == '{' {
+                depth++
+            } else {
+                depth--
+            }
+        }
+
+        if depth == 0 {
+            return nil
+        }
+    }
+}
+
+func (h *HLT) set_field(name string, value json.RawMessage) error {
+    switch name {
+    case "version":
+        return json.Unmarshal(value, &h.Version)
+    case "width":
+        return json.Unmarshal(value, &h.Width)
+    case "height":
+        return json.Unmarshal(value, &h.Height)
+    case "num_players":
+        return json.Unmarshal(value, &h.NumPlayers)
+    case "num_frames":
+        return json.Unmarshal(value, &h.NumFrames)
+    case "player_names":
+        return json.Unmarshal(value, &h.PlayerNames)
+    case "productions":
+        return json.Unmarshal(value, &h.Productions)
+    }
+    return nil                                  // Unknown fields are ignored, as json.Unmarshal would
+}
+
+func (s *HLTStream) Next() ([][]Site, [][]int, error) {
+
+    if s.frames_left == false || s.frames.More() == false {
+        s.frames_left = false
+        return nil, nil, io.EOF
+    }
+
+    var frame [][]Site
+    err := s.frames.Decode(&frame)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    var moves [][]int
+
+    if s.moves_left && s.moves.More() {
+        err = s.moves.Decode(&moves)
+        if err != nil {
+            return nil, nil, err
+        }
+    } else {
+        s.moves_left = false
+    }
+
+    s.Turn++
+
+    return frame, moves, nil
+}
+
+func (s *HLTStream) Close() error {
+    var result error
+    for _, c := range s.closers {
+        err := c.Close()
+        if err != nil && result == nil {
+            result = err
+        }
+    }
+    s.closers = nil
+    return result
+}
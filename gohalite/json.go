@@ -1,12 +1,18 @@
 package gohalite
 
 import (
+    "compress/gzip"
     "encoding/json"
     "fmt"
+    "io"
     "io/ioutil"
+    "os"
+    "strings"
     "time"
 )
 
+const HLT_VERSION = 11          // What the official engine writes, and the visualiser expects
+
 type Site struct {
     Owner       int
     Strength    int
@@ -47,7 +53,13 @@ func (n *Site) MarshalJSON() ([]byte, error) {
 
 func LoadHLT(filename string) (*HLT, error) {
 
-    file, err := ioutil.ReadFile(filename)
+    infile, err := open_hlt(filename)
+    if err != nil {
+        return nil, err
+    }
+    defer infile.Close()
+
+    file, err := ioutil.ReadAll(infile)
     if err != nil {
         return nil, err
     }
@@ -62,10 +74,60 @@ func LoadHLT(filename string) (*HLT, error) {
     return hlt, nil
 }
 
+func open_hlt(filename string) (io.ReadCloser, error) {
+
+    // Returns a reader for the file, transparently gunzipping .gz files.
+
+    infile, err := os.Open(filename)
+    if err != nil {
+        return nil, err
+    }
+
+    if strings.HasSuffix(filename, ".gz") == false {
+        return infile, nil
+    }
+
+    zipreader, err := gzip.NewReader(infile)
+    if err != nil {
+        infile.Close()
+        return nil, err
+    }
+
+    return gzip_file{zipreader, infile}, nil
+}
+
+type gzip_file struct {
+    *gzip.Reader
+    underlying  *os.File
+}
+
+func (z gzip_file) Close() error {
+    z.Reader.Close()
+    return z.underlying.Close()
+}
+
 func (g *Game) SetBoardFromHLT(hlt *HLT, turn int, id int) error {
 
-    if len(hlt.Frames) <= turn {
-        return fmt.Errorf("SetBoardFromHLT: wanted turn %d but file only had %d frames", turn, len(hlt.Frames))
+    if turn < 0 || turn >= len(hlt.Frames) {
+        return fmt.Errorf("SetBoardFromHLT: wanted turn %d but file has %d frames", turn, len(hlt.Frames))
+    }
+
+    return g.SetBoardFromFrame(hlt, hlt.Frames[turn], turn, id)
+}
+
+func (g *Game) SetBoardFromFrame(hlt *HLT, frame [][]Site, turn int, id int) error {
+
+    // As SetBoardFromHLT(), but with the frame supplied separately (e.g. from an HLTStream).
+    // Only the header fields of hlt are used.
+
+    if len(hlt.Productions) != hlt.Height || len(frame) != hlt.Height {
+        return fmt.Errorf("SetBoardFromFrame: wrong number of rows for height %d", hlt.Height)
+    }
+
+    for y := 0 ; y < hlt.Height ; y++ {
+        if len(hlt.Productions[y]) != hlt.Width || len(frame[y]) != hlt.Width {
+            return fmt.Errorf("SetBoardFromFrame: row %d has wrong length for width %d", y, hlt.Width)
+        }
     }
 
     if g.Width != hlt.Width || g.Height != hlt.Height {
@@ -85,8 +147,8 @@ func (g *Game) SetBoardFromHLT(hlt *HLT, turn int, id int) error {
         for x := 0 ; x < g.Width ; x++ {
             i := g.XY_to_I(x, y)
             g.Production[i] = hlt.Productions[y][x]                 // note y,x format in source
-            g.Owner[i] = hlt.Frames[g.Turn][y][x].Owner             // note y,x format in source
-            g.Strength[i] = hlt.Frames[g.Turn][y][x].Strength       // note y,x format in source
+            g.Owner[i] = frame[y][x].Owner                          // note y,x format in source
+            g.Strength[i] = frame[y][x].Strength                    // note y,x format in source
         }
     }
 
@@ -101,8 +163,18 @@ func (g *Game) SetMovesFromHLT(hlt *HLT) error {
         return fmt.Errorf("SetMovesFromHLT: HLT dimensions didn't match game")
     }
 
-    if len(hlt.Moves) <= g.Turn {
-        return fmt.Errorf("SetMovesFromHLT: wanted turn %d but file only had %d movelists", g.Turn, len(hlt.Moves))
+    if g.Turn < 0 || g.Turn >= len(hlt.Moves) {
+        return fmt.Errorf("SetMovesFromHLT: wanted turn %d but file has %d movelists", g.Turn, len(hlt.Moves))
+    }
+
+    if len(hlt.Moves[g.Turn]) != g.Height {
+        return fmt.Errorf("SetMovesFromHLT: movelist %d has wrong number of rows", g.Turn)
+    }
+
+    for y := 0 ; y < g.Height ; y++ {
+        if len(hlt.Moves[g.Turn][y]) != g.Width {
+            return fmt.Errorf("SetMovesFromHLT: movelist %d row %d has wrong length", g.Turn, y)
+        }
     }
 
     for y := 0 ; y < g.Height ; y++ {
@@ -117,6 +189,32 @@ func (g *Game) SetMovesFromHLT(hlt *HLT) error {
 
 // Note that the HLT file stored in the game object (if any) is what we loaded the game from.
 // These functions that follow are to save to some other HLT file, not that one.
+//
+// The usual sequence is NewHLT(), then AddFrame() for the initial position, then AddMoves()
+// and AddFrame() once per turn, then SaveHLT().
+
+func NewHLT(g *Game, player_names []string) *HLT {
+
+    // Player names are optional; missing ones get a default, like the official engine.
+
+    h := new(HLT)
+    h.Version = HLT_VERSION
+    h.Width = g.Width
+    h.Height = g.Height
+    h.NumPlayers = g.InitialPlayerCount
+
+    for n := 0 ; n < h.NumPlayers ; n++ {
+        if n < len(player_names) {
+            h.PlayerNames = append(h.PlayerNames, player_names[n])
+        } else {
+            h.PlayerNames = append(h.PlayerNames, fmt.Sprintf("Player %d", n + 1))
+        }
+    }
+
+    h.SetProductions(g)
+
+    return h
+}
 
 func (h *HLT) AddFrame(g *Game) error {
 
@@ -135,7 +233,7 @@ func (h *HLT) AddFrame(g *Game) error {
         }
     }
 
-    h.NumFrames++
+    h.NumFrames = len(h.Frames)
 
     return nil
 }
@@ -173,3 +271,61 @@ func (h *HLT) SetProductions(g *Game) error {
 
     return nil
 }
+
+func (h *HLT) SaveHLT(filename string) error {
+
+    // Writes a file the official visualiser accepts. If the filename ends in .gz, the output is gzipped.
+    // The metadata is made consistent first; a file with the wrong number of move grids is refused.
+
+    if len(h.Frames) == 0 {
+        return fmt.Errorf("SaveHLT: no frames")
+    }
+
+    if len(h.Moves) != len(h.Frames) - 1 {
+        return fmt.Errorf("SaveHLT: %d frames but %d move grids (should be 1 fewer)", len(h.Frames), len(h.Moves))
+    }
+
+    if len(h.PlayerNames) != h.NumPlayers {
+        return fmt.Errorf("SaveHLT: %d player names for %d players", len(h.PlayerNames), h.NumPlayers)
+    }
+
+    if h.Version == 0 {
+        h.Version = HLT_VERSION
+    }
+
+    h.NumFrames = len(h.Frames)
+
+    if h.Moves == nil {
+        h.Moves = [][][]int{}               // So we write [] rather than null
+    }
+
+    outfile, err := os.Create(filename)
+    if err != nil {
+        return err
+    }
+    defer outfile.Close()
+
+    if strings.HasSuffix(filename, ".gz") == false {
+        return json.NewEncoder(outfile).Encode(h)
+    }
+
+    zipwriter := gzip.NewWriter(outfile)
+
+    err = json.NewEncoder(zipwriter).Encode(h)
+    if err != nil {
+        return err
+    }
+
+    return zipwriter.Close()                // Flushes; must happen before outfile.Close()
+}
+
+func (g *Game) DumpHLT(filename string) error {
+
+    // Write the current position as a single-frame HLT file, for offline debugging.
+    // It can be reloaded with LoadHLT() and SetBoardFromHLT(hlt, 0, id).
+
+    h := NewHLT(g, nil)
+    h.AddFrame(g)
+
+    return h.SaveHLT(filename)
+}
@@ -0,0 +1,176 @@
+package gohalite
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "time"
+)
+
+// Alternative JSON-lines protocol, so that tools (notebooks, visualisers) can drive a bot
+// without speaking the classic space-separated protocol. One JSON object per line:
+//
+//      engine -> bot:  {"id":1,"turn":-1,"width":30,"height":30,"productions":[[...]],"owners":[[...]],"strengths":[[...]]}
+//      bot -> engine:  {"name":"v52"}
+//      engine -> bot:  {"turn":0,"width":30,"height":30,"owners":[[...]],"strengths":[[...]]}
+//      bot -> engine:  {"moves":[{"x":3,"y":4,"dir":1}, ...]}
+//
+// Like the HLT format, grids are in y,x format. The id and productions are only needed
+// in the first frame.
+
+const (
+    PROTOCOL_CLASSIC = iota
+    PROTOCOL_JSON
+)
+
+var Protocol = PROTOCOL_CLASSIC
+
+type JSONFrame struct {
+    Id          int                 `json:"id,omitempty"`
+    Turn        int                 `json:"turn"`
+    Width       int                 `json:"width"`
+    Height      int                 `json:"height"`
+    Productions [][]int             `json:"productions,omitempty"`
+    Owners      [][]int             `json:"owners"`
+    Strengths   [][]int             `json:"strengths"`
+}
+
+type JSONMove struct {
+    X           int                 `json:"x"`
+    Y           int                 `json:"y"`
+    Dir         int                 `json:"dir"`
+}
+
+type JSONMoves struct {
+    Moves       []JSONMove          `json:"moves"`
+}
+
+func read_json_frame() (*JSONFrame, error) {
+
+    // Reads a whole line, however long. Blank lines are skipped.
+
+    var line []byte
+    var err error
+
+    for len(bytes.TrimSpace(line)) == 0 {
+        line, err = reader.ReadBytes('\n')
+        if err != nil && (err != io.EOF || len(bytes.TrimSpace(line)) == 0) {
+            return nil, err
+        }
+    }
+
+    frame := new(JSONFrame)
+    err = json.Unmarshal(line, frame)
+    if err != nil {
+        return nil, err
+    }
+    return frame, nil
+}
+
+func (g *Game) StartupJSON() error {
+
+    frame, err := read_json_frame()
+    if err != nil {
+        return err
+    }
+
+    g.GameStart = time.Now()                    // After 1st message received
+
+    if frame.Width <= 0 || frame.Height <= 0 {
+        return fmt.Errorf("StartupJSON: bad map size %dx%d", frame.Width, frame.Height)
+    }
+
+    g.Id = frame.Id
+    g.Width = frame.Width
+    g.Height = frame.Height
+    g.Size = g.Width * g.Height
+
+    g.MakeLookupTable()
+    g.MakeSlices()
+
+    g.Turn = -1
+
+    if len(frame.Productions) != g.Height {
+        return fmt.Errorf("StartupJSON: first frame needs %d rows of productions, got %d", g.Height, len(frame.Productions))
+    }
+
+    for y := 0 ; y < g.Height ; y++ {
+        if len(frame.Productions[y]) != g.Width {
+            return fmt.Errorf("StartupJSON: production row %d has length %d", y, len(frame.Productions[y]))
+        }
+        for x := 0 ; x < g.Width ; x++ {
+            g.Production[g.XY_to_I(x, y)] = frame.Productions[y][x]
+        }
+    }
+
+    err = g.set_map_from_json_frame(frame)
+    if err != nil {
+        return err
+    }
+
+    g.TurnStart = g.GameStart
+    g.InitialPlayerCount = g.CountPlayers()
+    return nil
+}
+
+func (g *Game) ParseMapJSON() error {
+
+    frame, err := read_json_frame()
+    if err != nil {
+        return err
+    }
+
+    g.TurnStart = time.Now()                    // Do this immediately after the frame arrives
+
+    err = g.set_map_from_json_frame(frame)
+    if err != nil {
+        return err
+    }
+
+    g.Turn = frame.Turn - 1                     // SetExtraState() will increment this
+    return nil
+}
+
+func (g *Game) set_map_from_json_frame(frame *JSONFrame) error {
+
+    if frame.Width != g.Width || frame.Height != g.Height {
+        return fmt.Errorf("JSON frame dimensions %dx%d didn't match game %dx%d", frame.Width, frame.Height, g.Width, g.Height)
+    }
+
+    if len(frame.Owners) != g.Height || len(frame.Strengths) != g.Height {
+        return fmt.Errorf("JSON frame has wrong number of rows")
+    }
+
+    for y := 0 ; y < g.Height ; y++ {
+        if len(frame.Owners[y]) != g.Width || len(frame.Strengths[y]) != g.Width {
+            return fmt.Errorf("JSON frame row %d has wrong length", y)
+        }
+        for x := 0 ; x < g.Width ; x++ {
+            i := g.XY_to_I(x, y)
+            g.Owner[i] = frame.Owners[y][x]
+            g.Strength[i] = frame.Strengths[y][x]
+        }
+    }
+
+    return nil
+}
+
+func (g *Game) SendMovesJSON() {
+
+    result := JSONMoves{Moves: []JSONMove{}}            // So we send [] rather than null
+
+    for i := 0 ; i < g.Size ; i++ {
+        if g.Owner[i] == g.Id && g.Moves[i] != STILL {
+            x, y := g.I_to_XY(i)
+            result.Moves = append(result.Moves, JSONMove{X: x, Y: y, Dir: g.Moves[i]})
+        }
+    }
+
+    json.NewEncoder(os.Stdout).Encode(result)           // Encode() appends the newline
+}
+
+func SendNameJSON(name string) {
+    json.NewEncoder(os.Stdout).Encode(map[string]string{"name": name})
+}
@@ -0,0 +1,150 @@
+package gohalite
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// Per-game log file names and retention. Every game gets its own file, named so that
+// concurrent local games can't interleave, and old logs are deleted so they don't pile
+// up forever. The file name isn't known until the initial messages have been read, but
+// the Logfile only opens its file on the first write, so it can be renamed until then.
+
+const LOG_PREFIX = "Log_"
+const LOG_SUFFIX = ".log"
+
+func (g *Game) GameLogName(version string) string {
+
+    // e.g. Log_v52_20170127-153012_p2_30x30_4711.log
+    //
+    // The process id is what keeps parallel local games apart: they can easily have the same
+    // seat and map size, and start in the same second.
+
+    return fmt.Sprintf("%s%s_%s_p%d_%dx%d_%d%s", LOG_PREFIX, version, g.GameStart.Format("20060102-150405"), g.Id, g.Width, g.Height, os.Getpid(), LOG_SUFFIX)
+}
+
+func (log *Logfile) SetFilename(outfilename string) {
+
+    // Anything already written stays in the old file; later entries go to the new one.
+
+    if log == nil {
+        return
+    }
+
+    if log.outfile != nil {
+        log.outfile.Close()
+        log.outfile = nil
+    }
+
+    log.outfilename = outfilename
+}
+
+func (log *Logfile) Filename() string {
+    if log == nil {
+        return ""
+    }
+    return log.outfilename
+}
+
+type log_file struct {
+    path            string
+    info            os.FileInfo
+    companions      []string        // Other files written for the same game, deleted along with it
+    size            int64           // Including the companions
+}
+
+type by_newest []*log_file
+
+func (s by_newest) Len() int { return len(s) }
+func (s by_newest) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s by_newest) Less(i, j int) bool { return s[i].info.ModTime().After(s[j].info.ModTime()) }
+
+func PruneLogs(dir string, version string, max_files int, max_bytes int64) (int, error) {
+
+    // Delete this version's oldest logs in dir until at most max_files - 1 remain, leaving room
+    // for the game about to start, and until they take at most max_bytes in total. A limit of
+    // 0 or less means no limit of that kind. Returns the number of files deleted. Only files
+    // named like GameLogName() would name them are considered, so other versions' logs, and
+    // anything else that happens to start with Log_, are left alone.
+    //
+    // Files the bot writes alongside a log (slow-turn dumps and profiles, panic dumps, PNGs)
+    // are named <log name without .log>_something, and go with that log: they count towards
+    // its size and are deleted with it. Any such file whose log is already gone is treated as
+    // a log in its own right.
+
+    paths, err := filepath.Glob(filepath.Join(dir, LOG_PREFIX + version + "_*"))
+    if err != nil {
+        return 0, err
+    }
+
+    var files []*log_file
+    var others []*log_file
+    logs := make(map[string]*log_file)       // By path without the suffix
+
+    for _, path := range paths {
+        info, err := os.Stat(path)
+        if err != nil || info.Mode().IsRegular() == false {
+            continue
+        }
+        f := &log_file{path: path, info: info, size: info.Size()}
+        if strings.HasSuffix(path, LOG_SUFFIX) {
+            logs[strings.TrimSuffix(path, LOG_SUFFIX)] = f
+            files = append(files, f)
+        } else {
+            others = append(others, f)
+        }
+    }
+
+    for _, other := range others {
+
+        var owner *log_file
+        owner_prefix := ""
+
+        for prefix, f := range logs {
+            if strings.HasPrefix(other.path, prefix + "_") && len(prefix) > len(owner_prefix) {
+                owner = f
+                owner_prefix = prefix
+            }
+        }
+
+        if owner == nil {
+            files = append(files, other)
+        } else {
+            owner.companions = append(owner.companions, other.path)
+            owner.size += other.size
+        }
+    }
+
+    sort.Stable(by_newest(files))
+
+    deleted := 0
+    var total int64
+    var errs []string
+
+    for count, f := range files {
+
+        total += f.size
+
+        too_many := max_files > 0 && count >= max_files - 1
+        too_big := max_bytes > 0 && total > max_bytes
+
+        if too_many || too_big {
+            for _, path := range append(f.companions, f.path) {
+                err := os.Remove(path)
+                if err != nil {
+                    errs = append(errs, err.Error())
+                    continue
+                }
+                deleted++
+            }
+        }
+    }
+
+    if len(errs) > 0 {
+        return deleted, fmt.Errorf("PruneLogs: %s", strings.Join(errs, "; "))
+    }
+    return deleted, nil
+}
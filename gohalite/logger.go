@@ -2,33 +2,195 @@ package gohalite
 
 import (
     "crypto/sha1"
+    "encoding/json"
     "fmt"
     "os"
     "strconv"
     "strings"
 )
 
+// Logging. Every entry has a level and a category. Categories can be switched off at any
+// time, in which case their entries cost almost nothing. Entries below the Logfile's level
+// aren't written, but are kept in a ring buffer which is written out as soon as something
+// logs at LOG_ERROR, so the run-up to a problem is still there to read.
+//
+// Output is plain text (one entry per line) or, if JSON is set, one JSON object per line.
+
+const (
+    LOG_DEBUG = iota
+    LOG_INFO
+    LOG_WARN
+    LOG_ERROR
+)
+
+var LOG_LEVEL_NAMES = []string{"debug", "info", "warn", "error"}
+
+const (
+    CAT_GENERAL = "general"
+    CAT_OPENING = "opening"
+    CAT_WAR = "war"
+    CAT_ATTRACT = "attract"
+    CAT_TIMING = "timing"
+)
+
+const LOG_RING_SIZE = 1000
+
 type Logfile struct {
     outfile         *os.File
     outfilename     string
     enabled         bool
     logged_once     map[string]bool
+    disabled        map[string]bool         // Categories switched off
+    ring            []string                // Buffered entries below the level, oldest first once full
+    ring_next       int
+
+    Level           int                     // Entries below this go to the ring buffer only
+    JSON            bool
+}
+
+type log_entry struct {
+    Turn            int         `json:"turn"`
+    Level           string      `json:"level"`
+    Category        string      `json:"category"`
+    Msg             string      `json:"msg"`
 }
 
 func NewLog(outfilename string, enabled bool) *Logfile {
-    logged_once := make(map[string]bool)
-    return &Logfile{nil, outfilename, enabled, logged_once}
+    return &Logfile{
+        outfilename: outfilename,
+        enabled: enabled,
+        logged_once: make(map[string]bool),
+        disabled: make(map[string]bool),
+        Level: LOG_INFO,
+    }
 }
 
-func (log *Logfile) Dump(format_string string, args ...interface{}) {
+// A Logfile is never part of a snapshot. These let gob skip over it; a decoded one is disabled.
 
+func (log *Logfile) GobEncode() ([]byte, error) {
+    return []byte{}, nil
+}
+
+func (log *Logfile) GobDecode(buf []byte) error {
+    log.logged_once = make(map[string]bool)
+    log.disabled = make(map[string]bool)
+    return nil
+}
+
+func ParseLogLevel(s string) (int, error) {
+    for level, name := range LOG_LEVEL_NAMES {
+        if strings.EqualFold(s, name) {
+            return level, nil
+        }
+    }
+    return 0, fmt.Errorf("ParseLogLevel: unknown level %q", s)
+}
+
+func (log *Logfile) SetCategory(category string, on bool) {
     if log == nil {
         return
     }
+    log.disabled[category] = !on
+}
+
+func (log *Logfile) SetCategories(spec string) {
+
+    // A comma separated list such as "war,attract" (only these) or "-timing,-opening" (all but these).
+
+    if log == nil || spec == "" {
+        return
+    }
+
+    for _, category := range strings.Split(spec, ",") {
+        category = strings.TrimSpace(category)
+        if strings.HasPrefix(category, "-") {
+            log.SetCategory(category[1:], false)
+        } else {
+            for _, other := range []string{CAT_GENERAL, CAT_OPENING, CAT_WAR, CAT_ATTRACT, CAT_TIMING} {
+                if _, ok := log.disabled[other]; ok == false {
+                    log.disabled[other] = true              // Naming a category switches off the unnamed ones...
+                }
+            }
+            log.SetCategory(category, true)
+        }
+    }
+
+    log.SetCategory(CAT_GENERAL, true)                      // ...except general, which has errors and the like
+}
+
+func (log *Logfile) CategoryEnabled(category string) bool {
+    return log != nil && log.enabled && log.disabled[category] == false
+}
+
+func (log *Logfile) Entry(level int, category string, turn int, format_string string, args ...interface{}) {
+
+    if log.CategoryEnabled(category) == false {
+        return
+    }
+
+    line := log.format(level, category, turn, fmt.Sprintf(format_string, args...))
+
+    if level < log.Level {
+        log.push_ring(line)
+        return
+    }
+
+    if level >= LOG_ERROR {
+        log.FlushRing()
+    }
+
+    log.write(line)
+}
+
+func (log *Logfile) Dump(format_string string, args ...interface{}) {
+    log.Entry(LOG_INFO, CAT_GENERAL, -1, format_string, args...)
+}
+
+func (log *Logfile) FlushRing() {
+
+    // Write out (and empty) the ring buffer. Called automatically on errors.
+
+    if log == nil || log.enabled == false || len(log.ring) == 0 {
+        return
+    }
 
-    if log.enabled == false {
+    n := len(log.ring)
+    lines := make([]string, 0, n)
+    lines = append(lines, log.ring[log.ring_next:]...)
+    lines = append(lines, log.ring[:log.ring_next]...)
+    log.ring = nil
+    log.ring_next = 0
+
+    log.write(log.format(LOG_INFO, CAT_GENERAL, -1, fmt.Sprintf("---- %d buffered entries follow ----", n)))
+    for _, line := range lines {
+        log.write(line)
+    }
+    log.write(log.format(LOG_INFO, CAT_GENERAL, -1, "---- end of buffered entries ----"))
+}
+
+func (log *Logfile) push_ring(line string) {
+    if len(log.ring) < LOG_RING_SIZE {
+        log.ring = append(log.ring, line)
         return
     }
+    log.ring[log.ring_next] = line
+    log.ring_next = (log.ring_next + 1) % LOG_RING_SIZE
+}
+
+func (log *Logfile) format(level int, category string, turn int, msg string) string {
+
+    if log.JSON {
+        b, _ := json.Marshal(log_entry{Turn: turn, Level: LOG_LEVEL_NAMES[level], Category: category, Msg: msg})
+        return string(b)
+    }
+
+    if level == LOG_INFO && category == CAT_GENERAL {       // The common case looks just as it always did
+        return msg
+    }
+    return fmt.Sprintf("%s %s: %s", strings.ToUpper(LOG_LEVEL_NAMES[level]), category, msg)
+}
+
+func (log *Logfile) write(line string) {
 
     if log.outfile == nil {
 
@@ -44,42 +206,71 @@ func (log *Logfile) Dump(format_string string, args ...interface{}) {
 
         if err != nil {
             log.enabled = false
+            fmt.Fprintf(os.Stderr, "Logging disabled: %v\n", err)     // Not stdout, which is the engine's
             return
         }
     }
 
-    fmt.Fprintf(log.outfile, format_string, args...)
-    fmt.Fprintf(log.outfile, "\r\n")                    // Because I use Windows...
+    fmt.Fprintf(log.outfile, "%s\r\n", line)                  // Because I use Windows...
 }
 
 func (g *Game) Log(format_string string, args ...interface{}) {
+    g.LogAt(LOG_INFO, CAT_GENERAL, format_string, args...)
+}
+
+func (g *Game) LogAt(level int, category string, format_string string, args ...interface{}) {
 
     if g.IsSim {
         return
     }
 
-    g.Logfile.Dump(format_string, args...)
+    g.Logfile.Entry(level, category, g.Turn, format_string, args...)
+}
+
+func (g *Game) LogCat(category string, format_string string, args ...interface{}) {
+    g.LogAt(LOG_INFO, category, format_string, args...)
+}
+
+func (g *Game) LogDebug(category string, format_string string, args ...interface{}) {
+    g.LogAt(LOG_DEBUG, category, format_string, args...)
+}
+
+func (g *Game) LogWarn(category string, format_string string, args ...interface{}) {
+    g.LogAt(LOG_WARN, category, format_string, args...)
+}
+
+func (g *Game) LogError(category string, format_string string, args ...interface{}) {
+    g.LogAt(LOG_ERROR, category, format_string, args...)
 }
 
 func (g *Game) LogOnce(format_string string, args ...interface{}) bool {
 
-    if g.IsSim {
+    if g.IsSim || g.Logfile == nil {
         return false
     }
 
     if g.Logfile.logged_once[format_string] == false {
         g.Logfile.logged_once[format_string] = true         // Note that it's format_string that is checked / saved
-        g.Logfile.Dump(format_string, args...)
+        g.Log(format_string, args...)
         return true
     }
     return false
 }
 
 func (g *Game) LogInSim(format_string string, args ...interface{}) {
-    g.Logfile.Dump(format_string, args...)
+    g.Logfile.Entry(LOG_INFO, CAT_GENERAL, g.Turn, format_string, args...)
 }
 
 func (g *Game) LogValueMap(value_map []int, translate map[int]string) {
+    for _, line := range g.ValueMapLines(value_map, translate) {
+        g.Log("%s", line)
+    }
+    g.Log("")
+}
+
+func (g *Game) ValueMapLines(value_map []int, translate map[int]string) []string {
+
+    // Render a value map as one string per row, with every cell padded to the same width.
 
     var add string
     var ok bool
@@ -104,15 +295,17 @@ func (g *Game) LogValueMap(value_map []int, translate map[int]string) {
 
     format_string := fmt.Sprintf(" %%%ds", max_width)
 
+    var result []string
+
     s := ""
     for i := 0 ; i < g.Size ; i++ {
         s += fmt.Sprintf(format_string, all_strings[i])
         if i % g.Width == g.Width - 1 {
-            g.Log(s)
+            result = append(result, s)
             s = ""
         }
     }
-    g.Log("")
+    return result
 }
 
 func (g *Game) LogAttractMap(attraction_map []int) {
@@ -165,7 +358,7 @@ func (g *Game) LogPredictions(predicted_strengths []int, my_forces_set map[int]b
             s += ". "
         }
         if i % g.Width == g.Width - 1 {
-            g.Log(s)
+            g.Log("%s", s)
             s = ""
         }
     }
@@ -200,7 +393,7 @@ func (g *Game) LogList(list []int) {
             s += "."
         }
         if i % g.Width == g.Width - 1 {
-            g.Log(s)
+            g.Log("%s", s)
             s = ""
         }
     }
@@ -224,8 +417,19 @@ func (g *Game) LogLists(lists ...[]int) {
     g.LogValueMap(display, map[int]string{-1:"?", 0:"."})
 }
 
+var MOVE_GLYPHS = map[int]string{0:".", 1:"^", 2:">", 3:"v", 4:"<"}
+
 func (g *Game) LogMoves() {
-    g.LogValueMap(g.Moves, map[int]string{0:".", 1:"^", 2:">", 3:"v", 4:"<"})
+    g.LogValueMap(g.Moves, MOVE_GLYPHS)
+}
+
+func (g *Game) LogMovementNotes() {
+    for i := 0 ; i < g.Size ; i++ {
+        if g.HasOrders[i] {
+            x, y := g.I_to_XY(i)
+            g.Log("[%d,%d] %s (%s)", x, y, Dir_to_str(g.Moves[i]), g.MovementNotes[i])
+        }
+    }
 }
 
 func (g *Game) LogOverallocation(cumulative int) int {
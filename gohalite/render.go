@@ -0,0 +1,164 @@
+package gohalite
+
+import (
+    "fmt"
+    "image"
+    "image/color"
+    "image/draw"
+    "image/gif"
+    "image/png"
+    "os"
+    "strings"
+)
+
+// Rendering boards and replays as images, using only the standard library. Owners get
+// their usual colour, with strength as brightness; production and moves are optional overlays.
+
+type RenderOptions struct {
+    CellSize        int         // Pixels per cell
+    Production      bool        // Draw production as a dark square, bigger for more production
+    Moves           bool        // Draw moves as lines from the centre of the moving cell
+}
+
+var DefaultRenderOptions = RenderOptions{CellSize: 8, Production: false, Moves: true}
+
+func (g *Game) RenderImage(opts RenderOptions) *image.RGBA {
+
+    s := opts.CellSize
+    if s < 1 {
+        s = 1
+    }
+
+    img := image.NewRGBA(image.Rect(0, 0, g.Width * s, g.Height * s))
+
+    max_production := 1
+    for i := 0 ; i < g.Size ; i++ {
+        if g.Production[i] > max_production {
+            max_production = g.Production[i]
+        }
+    }
+
+    for i := 0 ; i < g.Size ; i++ {
+
+        x, y := g.I_to_XY(i)
+        left, top := x * s, y * s
+
+        draw.Draw(img, image.Rect(left, top, left + s, top + s), image.NewUniform(ShadedColour(g.Owner[i], g.Strength[i])), image.Point{}, draw.Src)
+
+        if opts.Production && s >= 3 {
+            side := 1 + (s - 2) * g.Production[i] / max_production
+            offset := (s - side) / 2
+            for py := top + offset ; py < top + offset + side ; py++ {
+                for px := left + offset ; px < left + offset + side ; px++ {
+                    c := img.RGBAAt(px, py)
+                    img.SetRGBA(px, py, color.RGBA{c.R / 2, c.G / 2, c.B / 2, 255})
+                }
+            }
+        }
+
+        if opts.Moves && g.Owner[i] != 0 && g.Moves[i] != STILL && s >= 3 {
+            cx, cy := left + s / 2, top + s / 2
+            dx, dy := 0, 0
+            switch g.Moves[i] {
+            case UP:
+                dy = -1
+            case DOWN:
+                dy = 1
+            case LEFT:
+                dx = -1
+            case RIGHT:
+                dx = 1
+            }
+            for step := 0 ; step < s / 2 ; step++ {
+                img.SetRGBA(cx + dx * step, cy + dy * step, color.RGBA{255, 255, 255, 255})
+            }
+        }
+    }
+
+    return img
+}
+
+func (g *Game) SavePNG(filename string, opts RenderOptions) error {
+
+    outfile, err := os.Create(filename)
+    if err != nil {
+        return err
+    }
+    defer outfile.Close()
+
+    return png.Encode(outfile, g.RenderImage(opts))
+}
+
+func (g *Game) LogPNG(tag string, opts RenderOptions) {
+
+    // For use alongside (or instead of) the text grids. The image goes next to the log file.
+
+    if g.IsSim || g.Logfile == nil || g.Logfile.enabled == false {
+        return
+    }
+
+    filename := fmt.Sprintf("%s_turn%d_%s.png", strings.TrimSuffix(g.Logfile.outfilename, ".log"), g.Turn, tag)
+
+    err := g.SavePNG(filename, opts)
+    if err != nil {
+        g.Log("Turn %d: couldn't save %s: %v", g.Turn, filename, err)
+        return
+    }
+    g.Log("Turn %d: saved %s", g.Turn, filename)
+}
+
+// -------------------------------------------------------------------------------------------------------------
+// Animated GIF of a whole replay.
+
+func render_palette() color.Palette {
+
+    // GIFs need a palette of at most 256 colours: 32 shades of each owner colour, plus white for the moves.
+
+    var result color.Palette
+    for owner := range OWNER_COLOURS {
+        for shade := 0 ; shade < 32 ; shade++ {
+            result = append(result, ShadedColour(owner, shade * 255 / 31))
+        }
+    }
+    result = append(result, color.RGBA{255, 255, 255, 255})
+    return result
+}
+
+func (h *HLT) SaveGIF(filename string, opts RenderOptions, delay int) error {
+
+    // delay is in 100ths of a second per frame.
+
+    problems := h.Validate()
+    if len(problems) > 0 {
+        return fmt.Errorf("SaveGIF: replay is not valid: %v", problems[0])
+    }
+
+    palette := render_palette()
+    anim := new(gif.GIF)
+
+    g := new(Game)
+
+    for turn := 0 ; turn < len(h.Frames) ; turn++ {
+
+        g.SetBoardFromHLT(h, turn, 0)
+        g.ClearMoves()
+        if turn < len(h.Moves) {
+            g.SetMovesFromHLT(h)
+        }
+
+        img := g.RenderImage(opts)
+        paletted := image.NewPaletted(img.Bounds(), palette)
+        draw.Draw(paletted, img.Bounds(), img, image.Point{}, draw.Src)      // Nearest colour, no dithering
+
+        anim.Image = append(anim.Image, paletted)
+        anim.Delay = append(anim.Delay, delay)
+    }
+
+    outfile, err := os.Create(filename)
+    if err != nil {
+        return err
+    }
+    defer outfile.Close()
+
+    return gif.EncodeAll(outfile, anim)
+}
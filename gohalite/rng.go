@@ -0,0 +1,49 @@
+package gohalite
+
+import (
+    "math/rand"
+)
+
+// The RNG used by the shuffles in both the library and the bot. Its source counts how many
+// values have been drawn since seeding, so the exact state can be saved (as seed and count)
+// in a snapshot and restored by reseeding and fast-forwarding. The global math/rand can't
+// be restored like that.
+
+type CountingSource struct {
+    src         rand.Source
+    seed        int64
+    draws       int64
+}
+
+func NewCountingSource(seed int64) *CountingSource {
+    return &CountingSource{src: rand.NewSource(seed), seed: seed}
+}
+
+func (s *CountingSource) Int63() int64 {
+    s.draws++
+    return s.src.Int63()
+}
+
+func (s *CountingSource) Seed(seed int64) {
+    s.src.Seed(seed)
+    s.seed = seed
+    s.draws = 0
+}
+
+func (s *CountingSource) State() (int64, int64) {
+    return s.seed, s.draws
+}
+
+func (s *CountingSource) Restore(seed int64, draws int64) {
+    s.Seed(seed)
+    for s.draws < draws {
+        s.Int63()
+    }
+}
+
+var RNGSource = NewCountingSource(1)
+var RNG = rand.New(RNGSource)
+
+func SeedRNG(seed int64) {
+    RNGSource.Seed(seed)
+}
@@ -0,0 +1,173 @@
+package gohalite
+
+import (
+    "fmt"
+    "os"
+    "runtime/pprof"
+    "time"
+)
+
+// Automatic CPU profiling of slow turns. After any turn that takes longer than Threshold
+// (a fraction of TIMEOUT), the position is kept as a single-frame HLT and a CPU profile is
+// taken over the next few turns, which are likely to be just as slow. At most MaxCaptures
+// profiles are taken per game. Off unless the bot creates one; all methods are no-ops on nil.
+//
+// None of this may itself push a turn over the limit:
+//
+//      - AfterTurn() runs between turns, when the engine's clock for the next turn may already
+//        be running but TurnStart won't see it. Whatever time it spends is taken off the next
+//        turn's budget, via Margin() in TurnDeadline().
+//      - The position is only copied in memory at first, and written out once its profile has
+//        stopped, which is also between turns. On the last turn everything is stopped and
+//        written, since the engine may kill us rather than close our input.
+//      - Turns being profiled also get PROFILE_MARGIN less, for the sampling overhead, and
+//        profiling stops early if a profiled turn still gets near its deadline.
+
+const PROFILE_MARGIN = 50 * time.Millisecond
+
+type slow_dump struct {
+    filename        string
+    hlt             *HLT
+}
+
+type SlowTurnProfiler struct {
+    Threshold       float64         // Fraction of TIMEOUT
+    Turns           int             // How many turns each profile covers
+    MaxCaptures     int
+    Captures        int
+    prefix          string          // Output files are <prefix>_slow_turn<N>.hlt / .prof
+    remaining       int             // Turns left in the current profile, 0 if not profiling
+    outfile         *os.File
+    pending         *slow_dump      // Position waiting for its profile to stop
+    debt            time.Duration   // Time AfterTurn() spent, to be taken off the next turn
+}
+
+func NewSlowTurnProfiler(prefix string, threshold float64, turns int, max_captures int) *SlowTurnProfiler {
+    if turns < 1 {
+        turns = 1
+    }
+    return &SlowTurnProfiler{Threshold: threshold, Turns: turns, MaxCaptures: max_captures, prefix: prefix}
+}
+
+func (p *SlowTurnProfiler) Margin() time.Duration {
+
+    // How much to take off this turn's budget.
+
+    if p == nil {
+        return 0
+    }
+
+    result := p.debt
+    if p.remaining > 0 {
+        result += PROFILE_MARGIN
+    }
+    return result
+}
+
+func (p *SlowTurnProfiler) AfterTurn(g *Game) {
+
+    // Called once the moves have been sent.
+
+    if p == nil {
+        return
+    }
+
+    start := time.Now()
+    elapsed := start.Sub(g.TurnStart)
+    near := g.TurnDeadline().Near()
+
+    p.debt = 0
+    defer func() {
+        p.debt = time.Since(start)
+    }()
+
+    if g.Turn >= g.LastTurn() {
+        p.Finish(g)
+        return
+    }
+
+    if p.remaining > 0 {
+        p.remaining--
+        if near {
+            g.LogWarn(CAT_TIMING, "Turn %d: deadline near while profiling, stopping early", g.Turn)
+            p.remaining = 0
+        }
+        if p.remaining == 0 {
+            p.stop(g)
+        }
+        return                              // Don't start a new capture on the turns we're profiling
+    }
+
+    if p.Captures >= p.MaxCaptures || float64(elapsed) < p.Threshold * float64(TIMEOUT) {
+        return
+    }
+
+    p.Captures++
+
+    g.LogWarn(CAT_TIMING, "Turn %d: slow turn (%v); capturing", g.Turn, elapsed)
+    g.LogBoardHash()
+
+    hlt := NewHLT(g, nil)
+    hlt.AddFrame(g)
+    p.pending = &slow_dump{fmt.Sprintf("%s_slow_turn%d.hlt", p.prefix, g.Turn), hlt}
+
+    prof_name := fmt.Sprintf("%s_slow_turn%d.prof", p.prefix, g.Turn)
+    outfile, err := os.Create(prof_name)
+    if err != nil {
+        g.LogWarn(CAT_TIMING, "Couldn't create %s: %v", prof_name, err)
+        p.write_pending(g)
+        return
+    }
+
+    err = pprof.StartCPUProfile(outfile)
+    if err != nil {
+        outfile.Close()
+        g.LogWarn(CAT_TIMING, "Couldn't start CPU profile: %v", err)
+        p.write_pending(g)
+        return
+    }
+
+    p.outfile = outfile
+    p.remaining = p.Turns
+    g.LogCat(CAT_TIMING, "CPU profile of the next %d turns going to %s", p.Turns, prof_name)
+}
+
+func (p *SlowTurnProfiler) stop(g *Game) {
+
+    if p.outfile != nil {
+        pprof.StopCPUProfile()
+        p.outfile.Close()
+        p.outfile = nil
+        p.remaining = 0
+        g.LogCat(CAT_TIMING, "Turn %d: CPU profile finished", g.Turn)
+    }
+
+    p.write_pending(g)
+}
+
+func (p *SlowTurnProfiler) write_pending(g *Game) {
+
+    if p.pending == nil {
+        return
+    }
+
+    err := p.pending.hlt.SaveHLT(p.pending.filename)
+    if err != nil {
+        g.LogWarn(CAT_TIMING, "Couldn't dump %s: %v", p.pending.filename, err)
+    } else {
+        g.LogCat(CAT_TIMING, "Slow position dumped to %s", p.pending.filename)
+    }
+
+    p.pending = nil
+}
+
+func (p *SlowTurnProfiler) Finish(g *Game) {
+
+    // At the end of the game: stop any profile still running and write its position.
+
+    if p == nil {
+        return
+    }
+
+    p.stop(g)
+}
@@ -0,0 +1,127 @@
+package gohalite
+
+import (
+    "encoding/gob"
+    "encoding/json"
+    "fmt"
+    "os"
+    "strings"
+)
+
+// Snapshots of the full Game state, so a paused position can be saved and reloaded exactly.
+// Unlike Copy(), the Game itself is serialised, so any new exported field is covered
+// automatically. Only Neighbours (rebuilt on load), and the Logfile and the various
+// diagnostics (not meaningful elsewhere) are left out. The bot's own globals go in BotInts and BotLists.
+//
+// Files ending in .json are written as JSON, anything else as gob.
+
+const SNAPSHOT_VERSION = 1
+
+type Snapshot struct {
+    Version     int
+    Game        *Game
+    BotInts     map[string]int
+    BotLists    map[string][]int
+}
+
+func (g *Game) MakeSnapshot() *Snapshot {
+
+    s := new(Snapshot)
+    s.Version = SNAPSHOT_VERSION
+    s.BotInts = make(map[string]int)
+    s.BotLists = make(map[string][]int)
+
+    // Shallow copy, minus the things we don't save. The slices are shared, which is fine
+    // since the snapshot is only ever encoded straight away.
+
+    tmp := *g
+    tmp.Neighbours = nil
+    tmp.Logfile = nil
+    tmp.Trace = nil
+    tmp.Timing = nil
+    tmp.SlowTurns = nil
+    s.Game = &tmp
+
+    return s
+}
+
+func (s *Snapshot) Save(filename string) error {
+
+    outfile, err := os.Create(filename)
+    if err != nil {
+        return err
+    }
+    defer outfile.Close()
+
+    if strings.HasSuffix(filename, ".json") {
+        encoder := json.NewEncoder(outfile)
+        encoder.SetIndent("", " ")
+        return encoder.Encode(s)
+    }
+
+    return gob.NewEncoder(outfile).Encode(s)
+}
+
+func LoadSnapshot(filename string) (*Snapshot, error) {
+
+    infile, err := os.Open(filename)
+    if err != nil {
+        return nil, err
+    }
+    defer infile.Close()
+
+    s := new(Snapshot)
+
+    if strings.HasSuffix(filename, ".json") {
+        err = json.NewDecoder(infile).Decode(s)
+    } else {
+        err = gob.NewDecoder(infile).Decode(s)
+    }
+
+    if err != nil {
+        return nil, err
+    }
+
+    if s.Version != SNAPSHOT_VERSION {
+        return nil, fmt.Errorf("LoadSnapshot: %s has version %d, wanted %d", filename, s.Version, SNAPSHOT_VERSION)
+    }
+
+    if s.Game == nil || s.Game.Size != s.Game.Width * s.Game.Height {
+        return nil, fmt.Errorf("LoadSnapshot: %s has no valid game", filename)
+    }
+
+    g := s.Game
+    g.MakeLookupTable()
+
+    // Gob drops empty slices and JSON can drop nil ones; either way, make sure every slice
+    // is the right length before anyone indexes into it.
+
+    if len(g.Production) != g.Size || len(g.Owner) != g.Size || len(g.Strength) != g.Size {
+        return nil, fmt.Errorf("LoadSnapshot: %s has a short board", filename)
+    }
+
+    if len(g.Moves) != g.Size {
+        g.Moves = make([]int, g.Size)
+    }
+    if len(g.HasOrders) != g.Size {
+        g.HasOrders = make([]bool, g.Size)
+    }
+    if len(g.Allocation) != g.Size {
+        g.Allocation = make([]int, g.Size)
+    }
+    if len(g.Incoming) != g.Size {
+        g.Incoming = make([]int, g.Size)
+    }
+    if len(g.MovementNotes) != g.Size {
+        g.MovementNotes = make([]string, g.Size)
+    }
+
+    if s.BotInts == nil {
+        s.BotInts = make(map[string]int)
+    }
+    if s.BotLists == nil {
+        s.BotLists = make(map[string][]int)
+    }
+
+    return s, nil
+}
@@ -1,14 +1,17 @@
 package gohalite
 
 import (
+    "math"
     "runtime"
-    "strconv"
-    "strings"
     "time"
 )
 
 func (g *Game) Update() {
-    g.ParseMap()
+    if Protocol == PROTOCOL_JSON {
+        g.exit_on_input_error(g.ParseMapJSON())
+    } else {
+        g.ParseMap()
+    }
     g.SetExtraState()
 }
 
@@ -17,6 +20,21 @@ func (g *Game) SetExtraState() {
     // Every turn, set state as appropriate.
 
     g.Turn += 1
+    g.ClearMoves()
+}
+
+func (g *Game) LastTurn() int {
+
+    // The engine stops after 10 * sqrt(width * height) turns, rounded down, and our first is 0.
+    // It may well kill us rather than close our input afterwards.
+
+    return int(math.Sqrt(float64(g.Width * g.Height)) * 10) - 1
+}
+
+func (g *Game) ClearMoves() {
+
+    // Set every piece to STILL with no orders, and reset the allocation bookkeeping to match.
+    // Also used to fall back to a safe move set if the AI fails mid-turn.
 
     for i := 0 ; i < g.Size ; i++ {
 
@@ -35,6 +53,11 @@ func (g *Game) SetExtraState() {
 
 func (g *Game) Startup() {
 
+    if Protocol == PROTOCOL_JSON {
+        g.exit_on_input_error(g.StartupJSON())
+        return
+    }
+
     g.ParseInitialMessages()    // Gets size info, needed for next calls
     g.MakeLookupTable()
     g.MakeSlices()
@@ -78,14 +101,17 @@ func (g *Game) MakeSlices() {
 }
 
 func (g *Game) ParseInitialMessages() {
-    scanner.Scan()
-    g.Id, _ = strconv.Atoi(scanner.Text())
+
+    var err error
+
+    g.Id, err = tokenizer.Next()
+    g.exit_on_input_error(err)
     g.GameStart = time.Now()                    // After 1st message received
 
-    scanner.Scan()
-    width_and_height := strings.Fields(scanner.Text())
-    g.Width, _ = strconv.Atoi(width_and_height[0])
-    g.Height, _ = strconv.Atoi(width_and_height[1])
+    g.Width, err = tokenizer.Next()
+    g.exit_on_input_error(err)
+    g.Height, err = tokenizer.Next()
+    g.exit_on_input_error(err)
     g.Size = g.Width * g.Height
 }
 
@@ -98,6 +124,7 @@ func (g *Game) SetMove(index, direction int, note string) {
 */
     g.HasOrders[index] = true
     g.MovementNotes[index] = note
+    g.trace_order(index, note, direction)
 
     old_direction := g.Moves[index]
     if old_direction == direction {
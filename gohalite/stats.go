@@ -0,0 +1,70 @@
+package gohalite
+
+// Per-turn, per-player statistics for a whole replay, for charting games.
+
+type PlayerTurnStats struct {
+    Turn            int     `json:"turn"`
+    Player          int     `json:"player"`
+    Territory       int     `json:"territory"`
+    Strength        int     `json:"strength"`
+    Production      int     `json:"production"`
+    CapWaste        int     `json:"cap_waste"`          // Strength lost to the 255 cap by this turn's moves
+    Gained          int     `json:"gained"`             // Cells gained since the previous turn
+    Lost            int     `json:"lost"`               // Cells lost since the previous turn
+}
+
+func HLTStats(hlt *HLT) ([]PlayerTurnStats, error) {
+
+    var result []PlayerTurnStats
+
+    g := new(Game)
+    var previous_owner []int
+
+    for turn := 0 ; turn < len(hlt.Frames) ; turn++ {
+
+        err := g.SetBoardFromHLT(hlt, turn, 0)
+        if err != nil {
+            return nil, err
+        }
+
+        have_moves := turn < len(hlt.Moves)
+        if have_moves {
+            err = g.SetMovesFromHLT(hlt)
+            if err != nil {
+                return nil, err
+            }
+        }
+
+        for id := 1 ; id <= hlt.NumPlayers ; id++ {
+
+            stats := PlayerTurnStats{
+                Turn: turn,
+                Player: id,
+                Territory: g.CellsOfPlayer(id),
+                Strength: g.StrengthOfPlayer(id),
+                Production: g.ProductionOfPlayer(id),
+            }
+
+            if have_moves {
+                stats.CapWaste = g.CapWasteOfPlayer(id)
+            }
+
+            if previous_owner != nil {
+                for i := 0 ; i < g.Size ; i++ {
+                    if g.Owner[i] == id && previous_owner[i] != id {
+                        stats.Gained++
+                    } else if g.Owner[i] != id && previous_owner[i] == id {
+                        stats.Lost++
+                    }
+                }
+            }
+
+            result = append(result, stats)
+        }
+
+        previous_owner = make([]int, g.Size)
+        copy(previous_owner, g.Owner)
+    }
+
+    return result, nil
+}
@@ -0,0 +1,178 @@
+package gohalite
+
+import (
+    "encoding/csv"
+    "fmt"
+    "os"
+    "sort"
+    "strconv"
+    "time"
+)
+
+// Per-phase timing telemetry. The AI wraps each phase of a turn:
+//
+//      start := time.Now()
+//      War(g, war_zones)
+//      g.TimePhase("War", start)
+//
+// EndTurn() files the turn's timings away and (optionally) writes them as a CSV row;
+// LogTimingSummary() logs p50 / p95 / max for every phase over the game so far. EndTurn()
+// does that itself on the last turn, since the engine may never tell us the game is over.
+// A phase that didn't run on some turn counts as zero for that turn's CSV row, but isn't
+// counted at all in the summary.
+
+type PhaseTimer struct {
+    Phases          []string                        // CSV columns, in order; others are only summarised
+    Samples         map[string][]time.Duration      // Every turn's time for each phase, this game
+    Turns           int
+    summarised      int                             // Turns covered by the last summary logged
+    current         map[string]time.Duration
+    outfile         *os.File
+    writer          *csv.Writer
+}
+
+func NewPhaseTimer(csv_filename string, phases []string) (*PhaseTimer, error) {
+
+    // If csv_filename is empty, no CSV is written.
+
+    t := &PhaseTimer{
+        Phases: phases,
+        Samples: make(map[string][]time.Duration),
+        current: make(map[string]time.Duration),
+    }
+
+    if csv_filename == "" {
+        return t, nil
+    }
+
+    outfile, err := os.Create(csv_filename)
+    if err != nil {
+        return t, err
+    }
+
+    t.outfile = outfile
+    t.writer = csv.NewWriter(outfile)
+
+    header := []string{"turn", "total_ms"}
+    for _, phase := range phases {
+        header = append(header, phase + "_ms")
+    }
+    t.writer.Write(header)
+    t.writer.Flush()
+
+    return t, t.writer.Error()
+}
+
+func (g *Game) TimePhase(phase string, start time.Time) {
+    if g.IsSim || g.Timing == nil {
+        return
+    }
+    g.Timing.current[phase] += time.Since(start)
+}
+
+func (t *PhaseTimer) EndTurn(g *Game) error {
+
+    if t == nil {
+        return nil
+    }
+
+    total := time.Since(g.TurnStart)
+
+    for phase, d := range t.current {
+        t.Samples[phase] = append(t.Samples[phase], d)
+    }
+    t.Samples["total"] = append(t.Samples["total"], total)
+    t.Turns++
+
+    current := t.current
+    t.current = make(map[string]time.Duration)
+
+    if g.Turn == g.LastTurn() {
+        g.LogTimingSummary()
+    }
+
+    if t.writer == nil {
+        return nil
+    }
+
+    row := []string{strconv.Itoa(g.Turn), ms_string(total)}
+    for _, phase := range t.Phases {
+        row = append(row, ms_string(current[phase]))
+    }
+
+    t.writer.Write(row)
+    t.writer.Flush()
+    return t.writer.Error()
+}
+
+func ms_string(d time.Duration) string {
+    return strconv.FormatFloat(float64(d) / float64(time.Millisecond), 'f', 3, 64)
+}
+
+func percentile(sorted []time.Duration, p int) time.Duration {
+    if len(sorted) == 0 {
+        return 0
+    }
+    return sorted[(len(sorted) - 1) * p / 100]
+}
+
+type by_duration []time.Duration
+
+func (s by_duration) Len() int { return len(s) }
+func (s by_duration) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s by_duration) Less(i, j int) bool { return s[i] < s[j] }
+
+func (t *PhaseTimer) Summary() []string {
+
+    // One line per phase: the CSV columns first, then anything else, then the whole turn.
+
+    if t == nil {
+        return nil
+    }
+
+    names := append([]string(nil), t.Phases...)
+
+    var extras []string
+    for phase := range t.Samples {
+        known := phase == "total"
+        for _, name := range names {
+            if name == phase {
+                known = true
+            }
+        }
+        if known == false {
+            extras = append(extras, phase)
+        }
+    }
+    sort.Strings(extras)
+
+    names = append(names, extras...)
+    names = append(names, "total")
+
+    result := []string{fmt.Sprintf("%-20s %6s %12s %12s %12s", "phase", "turns", "p50", "p95", "max")}
+
+    for _, phase := range names {
+        samples := append([]time.Duration(nil), t.Samples[phase]...)
+        if len(samples) == 0 {
+            continue
+        }
+        sort.Sort(by_duration(samples))
+        result = append(result, fmt.Sprintf("%-20s %6d %12v %12v %12v", phase, len(samples),
+            percentile(samples, 50), percentile(samples, 95), samples[len(samples) - 1]))
+    }
+
+    return result
+}
+
+func (g *Game) LogTimingSummary() {
+
+    if g.Timing == nil || g.Timing.Turns == g.Timing.summarised {      // Nothing new since the last one
+        return
+    }
+    g.Timing.summarised = g.Timing.Turns
+
+    g.LogCat(CAT_TIMING, "Phase timings over %d turns:", g.Timing.Turns)
+    for _, line := range g.Timing.Summary() {
+        g.LogCat(CAT_TIMING, "%s", line)
+    }
+}
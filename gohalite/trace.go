@@ -0,0 +1,166 @@
+package gohalite
+
+import (
+    "bufio"
+    "encoding/json"
+    "os"
+)
+
+// Structured decision trace. When g.Trace is set, the AI records, for each cell it considers,
+// the candidate directions with their scores and why any were rejected; SetMove() records the
+// routine that gave the final order. At the end of the turn Flush() writes one NDJSON line:
+//
+//      {"turn":5,"player":1,"orders":[{"x":3,"y":4,"routine":"Attract","dir":2,"candidates":[...]}, ...]}
+//
+// All the methods are no-ops when tracing is off, so the AI can call them unconditionally.
+// Sims are never traced, since Copy() doesn't carry the tracer over.
+
+type Candidate struct {
+    Dir             int         `json:"dir"`
+    Score           float64     `json:"score"`
+    Rejected        string      `json:"rejected,omitempty"`
+}
+
+type OrderTrace struct {
+    X               int         `json:"x"`
+    Y               int         `json:"y"`
+    Routine         string      `json:"routine"`
+    Dir             int         `json:"dir"`
+    Ordered         bool        `json:"ordered"`               // False if the routine looked at the cell but gave no order
+    Candidates      []Candidate `json:"candidates,omitempty"`
+    Reasons         []string    `json:"reasons,omitempty"`
+}
+
+type TurnTrace struct {
+    Turn            int             `json:"turn"`
+    Player          int             `json:"player"`
+    Orders          []*OrderTrace   `json:"orders"`
+}
+
+type Tracer struct {
+    Cells           map[int]*OrderTrace         // This turn's records, by index
+    AttractionMap   []int                       // This turn's attraction map as Attract() saw it, if any
+    outfile         *os.File
+    writer          *bufio.Writer
+}
+
+func NewTracer(filename string) (*Tracer, error) {
+
+    outfile, err := os.Create(filename)
+    if err != nil {
+        return nil, err
+    }
+
+    return &Tracer{Cells: make(map[int]*OrderTrace), outfile: outfile, writer: bufio.NewWriter(outfile)}, nil
+}
+
+func NewMemoryTracer() *Tracer {
+
+    // A tracer that never writes anything; the caller inspects Cells directly.
+
+    return &Tracer{Cells: make(map[int]*OrderTrace)}
+}
+
+func (g *Game) trace_record(i int, routine string) *OrderTrace {
+
+    // Get the record for cell i, starting a fresh one if a different routine now has it.
+
+    rec, ok := g.Trace.Cells[i]
+    if ok == false || rec.Routine != routine {
+        x, y := g.I_to_XY(i)
+        rec = &OrderTrace{X: x, Y: y, Routine: routine, Dir: g.Moves[i]}
+        g.Trace.Cells[i] = rec
+    }
+    return rec
+}
+
+func (g *Game) TraceCandidate(i int, routine string, dir int, score float64, rejected string) {
+    if g.Trace == nil {
+        return
+    }
+    rec := g.trace_record(i, routine)
+    rec.Candidates = append(rec.Candidates, Candidate{Dir: dir, Score: score, Rejected: rejected})
+}
+
+func (g *Game) TraceReason(i int, routine string, reason string) {
+    if g.Trace == nil {
+        return
+    }
+    rec := g.trace_record(i, routine)
+    rec.Reasons = append(rec.Reasons, reason)
+}
+
+func (g *Game) trace_order(i int, routine string, direction int) {
+    if g.Trace == nil {
+        return
+    }
+    rec := g.trace_record(i, routine)
+    rec.Dir = direction
+    rec.Ordered = true
+}
+
+func (g *Game) TraceAttractionMap(attraction_map []int) {
+    if g.Trace == nil {
+        return
+    }
+    g.Trace.AttractionMap = append([]int(nil), attraction_map...)
+}
+
+func (g *Game) TraceFor(i int) *OrderTrace {
+    if g.Trace == nil {
+        return nil
+    }
+    return g.Trace.Cells[i]
+}
+
+func (t *Tracer) Flush(g *Game) error {
+
+    if t == nil {
+        return nil
+    }
+
+    tt := TurnTrace{Turn: g.Turn, Player: g.Id, Orders: []*OrderTrace{}}
+
+    for i := 0 ; i < g.Size ; i++ {             // In index order, so the output is deterministic
+        rec, ok := t.Cells[i]
+        if ok {
+            tt.Orders = append(tt.Orders, rec)
+        }
+    }
+
+    t.Cells = make(map[int]*OrderTrace)
+    t.AttractionMap = nil
+
+    if t.writer == nil {
+        return nil
+    }
+
+    err := json.NewEncoder(t.writer).Encode(tt)
+    if err != nil {
+        return err
+    }
+    return t.writer.Flush()
+}
+
+func LoadTraces(filename string) ([]TurnTrace, error) {
+
+    infile, err := os.Open(filename)
+    if err != nil {
+        return nil, err
+    }
+    defer infile.Close()
+
+    var result []TurnTrace
+
+    decoder := json.NewDecoder(infile)
+    for decoder.More() {
+        var tt TurnTrace
+        err = decoder.Decode(&tt)
+        if err != nil {
+            return nil, err
+        }
+        result = append(result, tt)
+    }
+
+    return result, nil
+}
@@ -0,0 +1,102 @@
+package gohalite
+
+import (
+    "fmt"
+)
+
+// Structural checks on an HLT, so corrupted or truncated replays can be rejected up front
+// rather than causing index panics later. Every problem found is reported, not just the first.
+
+func (h *HLT) Validate() []error {
+
+    var problems []error
+
+    add := func(format_string string, args ...interface{}) {
+        problems = append(problems, fmt.Errorf(format_string, args...))
+    }
+
+    if h.Width <= 0 || h.Height <= 0 {
+        add("bad dimensions %dx%d", h.Width, h.Height)
+        return problems                         // Nothing else can be checked meaningfully
+    }
+
+    if h.NumPlayers <= 0 {
+        add("bad num_players %d", h.NumPlayers)
+    }
+
+    if len(h.PlayerNames) != h.NumPlayers {
+        add("%d player names for %d players", len(h.PlayerNames), h.NumPlayers)
+    }
+
+    if h.NumFrames != len(h.Frames) {
+        add("num_frames is %d but there are %d frames", h.NumFrames, len(h.Frames))
+    }
+
+    if len(h.Frames) == 0 {
+        add("no frames")
+    } else if len(h.Moves) != len(h.Frames) - 1 {
+        add("%d move grids for %d frames (should be 1 fewer)", len(h.Moves), len(h.Frames))
+    }
+
+    // Productions...
+
+    if len(h.Productions) != h.Height {
+        add("productions has %d rows, wanted %d", len(h.Productions), h.Height)
+    }
+
+    for y, row := range h.Productions {
+        if len(row) != h.Width {
+            add("productions row %d has length %d, wanted %d", y, len(row), h.Width)
+        }
+        for x, val := range row {
+            if val < 0 {
+                add("production at [%d,%d] is negative: %d", x, y, val)
+            }
+        }
+    }
+
+    // Frames...
+
+    for n, frame := range h.Frames {
+
+        if len(frame) != h.Height {
+            add("frame %d has %d rows, wanted %d", n, len(frame), h.Height)
+        }
+
+        for y, row := range frame {
+            if len(row) != h.Width {
+                add("frame %d row %d has length %d, wanted %d", n, y, len(row), h.Width)
+            }
+            for x, site := range row {
+                if site.Owner < 0 || site.Owner > h.NumPlayers {
+                    add("frame %d [%d,%d]: owner %d out of range 0..%d", n, x, y, site.Owner, h.NumPlayers)
+                }
+                if site.Strength < 0 || site.Strength > 255 {
+                    add("frame %d [%d,%d]: strength %d out of range 0..255", n, x, y, site.Strength)
+                }
+            }
+        }
+    }
+
+    // Moves...
+
+    for n, moves := range h.Moves {
+
+        if len(moves) != h.Height {
+            add("move grid %d has %d rows, wanted %d", n, len(moves), h.Height)
+        }
+
+        for y, row := range moves {
+            if len(row) != h.Width {
+                add("move grid %d row %d has length %d, wanted %d", n, y, len(row), h.Width)
+            }
+            for x, move := range row {
+                if move < STILL || move > WEST {
+                    add("move grid %d [%d,%d]: move %d out of range 0..4", n, x, y, move)
+                }
+            }
+        }
+    }
+
+    return problems
+}
@@ -0,0 +1,178 @@
+//go:build tools
+// +build tools
+
+package main
+
+// Golden-move regression suite. A directory holds replays plus a goldens.json manifest:
+//
+//      [
+//          {"hlt": "game1.hlt", "turn": 120, "player": 2, "moves_hash": "...", "moves": [[x, y, dir], ...]},
+//          ...
+//      ]
+//
+// For each entry the position is rebuilt, MakeMoves() is run, and the MovesHash() compared.
+// With -update-goldens the manifest is rewritten with the current moves instead; new fixtures
+// can be added by hand with the hash and moves left empty, then updated.
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "path/filepath"
+
+    hal "./gohalite"
+)
+
+const GOLDEN_MANIFEST = "goldens.json"
+
+var golden_dir = flag.String("golden", "", "run the golden-move regression suite in this directory, and exit")
+var update_goldens = flag.Bool("update-goldens", false, "with -golden, store the current moves as the new goldens")
+
+func init() {
+    OfflineModes = append(OfflineModes, func() (bool, bool) {
+        if *golden_dir == "" {
+            return false, false
+        }
+        return true, RunGoldens(*golden_dir, *update_goldens)
+    })
+}
+
+type GoldenFixture struct {
+    HLT             string      `json:"hlt"`
+    Turn            int         `json:"turn"`
+    Player          int         `json:"player"`
+    MovesHash       string      `json:"moves_hash"`
+    Moves           [][3]int    `json:"moves"`
+}
+
+func RunGoldens(dir string, update bool) bool {
+
+    manifest := filepath.Join(dir, GOLDEN_MANIFEST)
+
+    data, err := ioutil.ReadFile(manifest)
+    if err != nil {
+        fmt.Println(err)
+        return false
+    }
+
+    var fixtures []GoldenFixture
+    err = json.Unmarshal(data, &fixtures)
+    if err != nil {
+        fmt.Printf("%s: %v\n", manifest, err)
+        return false
+    }
+
+    hlts := make(map[string]*hal.HLT)
+    failures := 0
+    updated := 0
+
+    for n, fixture := range fixtures {
+
+        name := fmt.Sprintf("%s turn %d player %d", fixture.HLT, fixture.Turn, fixture.Player)
+
+        hlt, ok := hlts[fixture.HLT]
+        if ok == false {
+            hlt, err = hal.LoadHLT(filepath.Join(dir, fixture.HLT))
+            if err != nil {
+                fmt.Printf("FAIL  %s: %v\n", name, err)
+                failures++
+                continue
+            }
+            hlts[fixture.HLT] = hlt
+        }
+
+        g, err := PositionFromHLT(hlt, fixture.Turn, fixture.Player)
+        if err != nil {
+            fmt.Printf("FAIL  %s: %v\n", name, err)
+            failures++
+            continue
+        }
+
+        MovesFromPosition(g)
+
+        moves_hash := g.MovesHash()
+
+        if update {
+            if moves_hash != fixture.MovesHash {
+                fmt.Printf("UPDATED  %s\n", name)
+                updated++
+            }
+            fixtures[n].MovesHash = moves_hash
+            fixtures[n].Moves = moves_list(g)
+            continue
+        }
+
+        if moves_hash == fixture.MovesHash {
+            fmt.Printf("ok    %s\n", name)
+            continue
+        }
+
+        failures++
+        fmt.Printf("FAIL  %s: moves hash %s, golden %s\n", name, moves_hash, fixture.MovesHash)
+        print_changed_moves(g, fixture.Moves)
+    }
+
+    if update {
+
+        // Fixtures that failed to load are written back unchanged.
+
+        data, err = json.MarshalIndent(fixtures, "", "    ")
+        if err == nil {
+            err = ioutil.WriteFile(manifest, data, 0666)
+        }
+        if err != nil {
+            fmt.Println(err)
+            return false
+        }
+        fmt.Printf("Updated %d of %d goldens in %s\n", updated, len(fixtures), manifest)
+        return failures == 0
+    }
+
+    fmt.Printf("%d of %d fixtures passed\n", len(fixtures) - failures, len(fixtures))
+    return failures == 0
+}
+
+func moves_list(g *hal.Game) [][3]int {
+
+    // Same moves as MovesHash() considers, i.e. our pieces that aren't STILL.
+
+    result := [][3]int{}
+    for i := 0 ; i < g.Size ; i++ {
+        if g.Owner[i] == g.Id && g.Moves[i] != hal.STILL {
+            x, y := g.I_to_XY(i)
+            result = append(result, [3]int{x, y, g.Moves[i]})
+        }
+    }
+    return result
+}
+
+func print_changed_moves(g *hal.Game, golden [][3]int) {
+
+    // Print a LogMoves() style map showing only the moves that differ from the golden ones,
+    // then list them along with whatever gave the order.
+
+    golden_moves := make([]int, g.Size)
+    for _, move := range golden {
+        golden_moves[g.XY_to_I(move[0], move[1])] = move[2]
+    }
+
+    changed := make([]int, g.Size)
+    for i := 0 ; i < g.Size ; i++ {
+        changed[i] = -1
+        if g.Owner[i] == g.Id && g.Moves[i] != golden_moves[i] {
+            changed[i] = g.Moves[i]
+        }
+    }
+
+    for _, line := range g.ValueMapLines(changed, map[int]string{-1:".", 0:"o", 1:"^", 2:">", 3:"v", 4:"<"}) {
+        fmt.Printf("      %s\n", line)
+    }
+
+    for i := 0 ; i < g.Size ; i++ {
+        if changed[i] != -1 {
+            x, y := g.I_to_XY(i)
+            fmt.Printf("      [%d,%d] golden %s, now %s (%s)\n", x, y, hal.Dir_to_str(golden_moves[i]), hal.Dir_to_str(g.Moves[i]), g.MovementNotes[i])
+        }
+    }
+}
@@ -0,0 +1,58 @@
+//go:build tools
+// +build tools
+
+package main
+
+// Setting up the bot to move from an arbitrary position in a replay, as used by the
+// regression and debugging tools, along with the flags they share. This mimics what
+// AI_Startup() would have done had we been loaded into the midgame, minus the logging.
+
+import (
+    "flag"
+    "fmt"
+
+    hal "./gohalite"
+)
+
+var position_player = flag.Int("player", 1, "with -audit or -explain, the player to move as")
+var position_turn = flag.Int("turn", -1, "with -audit or -explain, the turn to check (-audit default: all)")
+
+func PositionFromHLT(hlt *hal.HLT, turn int, id int) (*hal.Game, error) {
+
+    if id < 1 || id > hlt.NumPlayers {
+        return nil, fmt.Errorf("PositionFromHLT: player %d is not in a %d player game", id, hlt.NumPlayers)
+    }
+
+    g := new(hal.Game)
+
+    err := g.SetBoardFromHLT(hlt, turn, id)
+    if err != nil {
+        return nil, err
+    }
+
+    g.ClearMoves()                          // SetBoardFromHLT() leaves these alone
+    g.OpeningFlag = false
+
+    // The start location is wherever we were on the first frame...
+
+    first := new(hal.Game)
+    first.SetBoardFromHLT(hlt, 0, id)
+    first.SetStartLoc()
+    g.StartLoc = first.StartLoc
+
+    return g, nil
+}
+
+func MovesFromPosition(g *hal.Game) {
+
+    // Make moves exactly as the real bot would, from a clean slate of globals and RNG,
+    // except that nothing is skipped for lack of time.
+
+    g.Untimed = true
+
+    BestNiceMin = NORMAL_NICE_MIN
+    NiceMin = BestNiceMin
+
+    hal.SeedRNG(1)
+    MakeMoves(g, nil)
+}
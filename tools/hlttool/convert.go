@@ -0,0 +1,27 @@
+package main
+
+import (
+    "fmt"
+)
+
+func init() {
+    Commands["convert"] = Command{
+        Usage: "convert <in> <out>",
+        Help: "convert between .hlt, .hlt.gz and binary .hltb replays",
+        Run: Convert,
+    }
+}
+
+func Convert(args []string) error {
+
+    if len(args) != 2 {
+        return fmt.Errorf("wanted 2 arguments, got %d", len(args))
+    }
+
+    h, err := LoadAny(args[0])
+    if err != nil {
+        return err
+    }
+
+    return SaveAny(h, args[1])
+}
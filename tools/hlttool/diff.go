@@ -0,0 +1,210 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+
+    hal "../../gohalite"
+)
+
+func init() {
+    Commands["diff"] = Command{
+        Usage: "diff <a> <b>",
+        Help: "show where two replays of the same map diverge",
+        Run: Diff,
+    }
+}
+
+func Diff(args []string) error {
+
+    if len(args) != 2 {
+        return fmt.Errorf("wanted 2 files, got %d", len(args))
+    }
+
+    hlt_a, err := load_valid(args[0])
+    if err != nil {
+        return err
+    }
+
+    hlt_b, err := load_valid(args[1])
+    if err != nil {
+        return err
+    }
+
+    if hlt_a.Width != hlt_b.Width || hlt_a.Height != hlt_b.Height {
+        return fmt.Errorf("different map sizes: %dx%d vs %dx%d", hlt_a.Width, hlt_a.Height, hlt_b.Width, hlt_b.Height)
+    }
+
+    ga := new(hal.Game)
+    gb := new(hal.Game)
+
+    first_frame_diff := -1
+    first_move_diff := -1
+
+    turns := len(hlt_a.Frames)
+    if len(hlt_b.Frames) < turns {
+        turns = len(hlt_b.Frames)
+    }
+
+    fmt.Printf("turn  cells  moves\n")
+
+    for turn := 0 ; turn < turns ; turn++ {
+
+        err = set_boards(ga, gb, hlt_a, hlt_b, turn)
+        if err != nil {
+            return err
+        }
+
+        if turn == 0 && production_differs(ga, gb) {
+            return fmt.Errorf("not the same map: productions differ")
+        }
+
+        cell_diffs := len(differing_cells(ga, gb))
+        move_diffs := 0
+
+        if turn < len(hlt_a.Moves) && turn < len(hlt_b.Moves) {
+            err = set_moves(ga, gb, hlt_a, hlt_b)
+            if err != nil {
+                return err
+            }
+            for i := 0 ; i < ga.Size ; i++ {
+                if ga.Moves[i] != gb.Moves[i] {
+                    move_diffs++
+                }
+            }
+        }
+
+        if cell_diffs > 0 || move_diffs > 0 {
+            fmt.Printf("%4d  %5d  %5d\n", turn, cell_diffs, move_diffs)
+        }
+
+        if cell_diffs > 0 && first_frame_diff == -1 {
+            first_frame_diff = turn
+        }
+        if move_diffs > 0 && first_move_diff == -1 {
+            first_move_diff = turn
+        }
+    }
+
+    if len(hlt_a.Frames) != len(hlt_b.Frames) {
+        fmt.Printf("\nGame lengths differ: %d vs %d frames\n", len(hlt_a.Frames), len(hlt_b.Frames))
+    }
+
+    if first_frame_diff == -1 && first_move_diff == -1 {
+        fmt.Printf("\nNo differences in the first %d frames.\n", turns)
+        return nil
+    }
+
+    // Show the first divergence. Differing moves always come before differing frames, unless
+    // the replays started from different positions...
+
+    if first_move_diff != -1 && (first_frame_diff == -1 || first_move_diff < first_frame_diff) {
+
+        fmt.Printf("\nFirst divergence: moves on turn %d\n\n", first_move_diff)
+
+        err = set_boards(ga, gb, hlt_a, hlt_b, first_move_diff)
+        if err != nil {
+            return err
+        }
+        err = set_moves(ga, gb, hlt_a, hlt_b)
+        if err != nil {
+            return err
+        }
+
+        print_side_by_side(ga, gb, ga.Moves, gb.Moves, hal.MOVE_GLYPHS)
+
+    } else {
+
+        fmt.Printf("\nFirst divergence: frame %d\n\n", first_frame_diff)
+
+        err = set_boards(ga, gb, hlt_a, hlt_b, first_frame_diff)
+        if err != nil {
+            return err
+        }
+
+        print_side_by_side(ga, gb, ga.Owner, gb.Owner, map[int]string{0:"."})
+    }
+
+    return nil
+}
+
+func load_valid(filename string) (*hal.HLT, error) {
+
+    // A malformed frame or move grid would otherwise be compared as whatever was loaded before it.
+
+    h, err := LoadAny(filename)
+    if err != nil {
+        return nil, err
+    }
+
+    problems := h.Validate()
+    if len(problems) > 0 {
+        return nil, fmt.Errorf("%s is not valid (%d problems, first: %v)", filename, len(problems), problems[0])
+    }
+
+    return h, nil
+}
+
+func set_boards(ga, gb *hal.Game, hlt_a, hlt_b *hal.HLT, turn int) error {
+    err := ga.SetBoardFromHLT(hlt_a, turn, 0)
+    if err != nil {
+        return err
+    }
+    return gb.SetBoardFromHLT(hlt_b, turn, 0)
+}
+
+func set_moves(ga, gb *hal.Game, hlt_a, hlt_b *hal.HLT) error {
+    err := ga.SetMovesFromHLT(hlt_a)
+    if err != nil {
+        return err
+    }
+    return gb.SetMovesFromHLT(hlt_b)
+}
+
+func production_differs(ga, gb *hal.Game) bool {
+    for i := 0 ; i < ga.Size ; i++ {
+        if ga.Production[i] != gb.Production[i] {
+            return true
+        }
+    }
+    return false
+}
+
+func differing_cells(ga, gb *hal.Game) []int {
+    var result []int
+    for i := 0 ; i < ga.Size ; i++ {
+        if ga.Owner[i] != gb.Owner[i] || ga.Strength[i] != gb.Strength[i] {
+            result = append(result, i)
+        }
+    }
+    return result
+}
+
+func print_side_by_side(ga, gb *hal.Game, map_a, map_b []int, translate map[int]string) {
+
+    // Three panels: a, b, and a mask of the cells where either the maps or the boards differ.
+
+    mask := make([]int, ga.Size)
+    for i := 0 ; i < ga.Size ; i++ {
+        if map_a[i] != map_b[i] {
+            mask[i] = 1
+        }
+    }
+    for _, i := range differing_cells(ga, gb) {
+        mask[i] = 1
+    }
+
+    lines_a := ga.ValueMapLines(map_a, translate)
+    lines_b := gb.ValueMapLines(map_b, translate)
+    lines_mask := ga.ValueMapLines(mask, map[int]string{0:".", 1:"X"})
+
+    width_a := len(lines_a[0])
+    width_b := len(lines_b[0])
+
+    fmt.Printf("%-*s  |%-*s  |%s\n", width_a, " a", width_b, " b", " differences")
+    fmt.Printf("%s\n", strings.Repeat("-", width_a + width_b + len(lines_mask[0]) + 6))
+
+    for y := 0 ; y < ga.Height ; y++ {
+        fmt.Printf("%s  |%s  |%s\n", lines_a[y], lines_b[y], lines_mask[y])
+    }
+}
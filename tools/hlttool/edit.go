@@ -0,0 +1,84 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+
+    hal "../../gohalite"
+)
+
+func init() {
+    Commands["slice"] = Command{
+        Usage: "slice <in> <out> <first> <last>",
+        Help: "keep only frames first to last",
+        Run: SliceCmd,
+    }
+    Commands["crop"] = Command{
+        Usage: "crop <in> <out> <x> <y> <width> <height>",
+        Help: "keep only a rectangle of the map",
+        Run: CropCmd,
+    }
+    Commands["remap"] = Command{
+        Usage: "remap <in> <out> <player>",
+        Help: "renumber players so the given one is player 1",
+        Run: RemapCmd,
+    }
+}
+
+func edit(args []string, want_ints int, op func(h *hal.HLT, vals []int) (*hal.HLT, error)) error {
+
+    // Common plumbing: load, parse the integer arguments, check, apply, check again, save.
+
+    if len(args) != 2 + want_ints {
+        return fmt.Errorf("wanted %d arguments, got %d", 2 + want_ints, len(args))
+    }
+
+    var vals []int
+    for _, arg := range args[2:] {
+        val, err := strconv.Atoi(arg)
+        if err != nil {
+            return err
+        }
+        vals = append(vals, val)
+    }
+
+    h, err := LoadAny(args[0])
+    if err != nil {
+        return err
+    }
+
+    problems := h.Validate()
+    if len(problems) > 0 {
+        return fmt.Errorf("input is not valid (%d problems, first: %v)", len(problems), problems[0])
+    }
+
+    result, err := op(h, vals)
+    if err != nil {
+        return err
+    }
+
+    problems = result.Validate()
+    if len(problems) > 0 {
+        return fmt.Errorf("result is not valid (%d problems, first: %v)", len(problems), problems[0])
+    }
+
+    return SaveAny(result, args[1])
+}
+
+func SliceCmd(args []string) error {
+    return edit(args, 2, func(h *hal.HLT, vals []int) (*hal.HLT, error) {
+        return h.Slice(vals[0], vals[1])
+    })
+}
+
+func CropCmd(args []string) error {
+    return edit(args, 4, func(h *hal.HLT, vals []int) (*hal.HLT, error) {
+        return h.Crop(vals[0], vals[1], vals[2], vals[3])
+    })
+}
+
+func RemapCmd(args []string) error {
+    return edit(args, 1, func(h *hal.HLT, vals []int) (*hal.HLT, error) {
+        return h.MakePlayerOne(vals[0])
+    })
+}
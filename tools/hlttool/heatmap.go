@@ -0,0 +1,76 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "strings"
+
+    hal "../../gohalite"
+)
+
+func init() {
+    Commands["heatmap"] = Command{
+        Usage: "heatmap [options] <file> <out.png|out.svg>",
+        Help: "draw a value map (attraction, distances...) for one position",
+        Run: Heatmap,
+    }
+}
+
+func Heatmap(args []string) error {
+
+    flags := flag.NewFlagSet("heatmap", flag.ContinueOnError)
+    turn := flags.Int("turn", 0, "turn of the position")
+    player := flags.Int("player", 1, "whose point of view")
+    which := flags.String("map", "attraction", strings.Join(VALUE_MAPS, "|"))
+    nice_min := flags.Int("nicemin", 1, "NiceMin for the attraction and frontier maps")
+    cell := flags.Int("cell", 12, "pixels per cell")
+    err := flags.Parse(args)
+    if err != nil {
+        return err
+    }
+
+    if flags.NArg() != 2 {
+        return fmt.Errorf("wanted 2 files, got %d", flags.NArg())
+    }
+
+    h, err := LoadAny(flags.Arg(0))
+    if err != nil {
+        return err
+    }
+
+    g := new(hal.Game)
+    err = g.SetBoardFromHLT(h, *turn, *player)
+    if err != nil {
+        return err
+    }
+
+    value_map, blanks, err := ValueMap(g, *which, *nice_min)
+    if err != nil {
+        return err
+    }
+
+    return g.SaveHeatmap(flags.Arg(1), value_map, *cell, blanks...)
+}
+
+var VALUE_MAPS = []string{"attraction", "enemy", "frontier", "production", "strength"}
+
+func ValueMap(g *hal.Game, which string, nice_min int) ([]int, []int, error) {
+
+    // Returns the value map, and the values in it which mean "nothing here".
+
+    switch which {
+    case "attraction":
+        value_map, _ := g.AttractionMap_v2(nice_min)
+        return value_map, nil, nil
+    case "enemy":
+        return g.EnemyDistances(), nil, nil
+    case "frontier":
+        return g.NiceFrontierDistances(nice_min), []int{0, 99}, nil     // Not ours, and voids
+    case "production":
+        return g.Production, nil, nil
+    case "strength":
+        return g.Strength, nil, nil
+    }
+
+    return nil, nil, fmt.Errorf("unknown map %q", which)
+}
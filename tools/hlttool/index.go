@@ -0,0 +1,255 @@
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+
+    hal "../../gohalite"
+)
+
+// A local index of a directory of replays, so that games can be listed by their properties
+// without loading them all again. The index is a JSON file, by default in the directory itself.
+
+const DEFAULT_INDEX_NAME = "hlt_index.json"
+
+type IndexEntry struct {
+    File            string      `json:"file"`
+    Width           int         `json:"width"`
+    Height          int         `json:"height"`
+    NumPlayers      int         `json:"num_players"`
+    PlayerNames     []string    `json:"player_names"`
+    Winner          int         `json:"winner"`            // Player with most territory on the last frame
+    Frames          int         `json:"frames"`
+    OurSeat         int         `json:"our_seat"`          // 0 if we didn't play
+}
+
+func init() {
+    Commands["index"] = Command{
+        Usage: "index [-o file] [-me name] <dir>",
+        Help: "scan a directory of replays into an index",
+        Run: IndexCmd,
+    }
+    Commands["query"] = Command{
+        Usage: "query [options] <index>",
+        Help: "list indexed games by size, players, result etc (-h for options)",
+        Run: QueryCmd,
+    }
+}
+
+func IndexCmd(args []string) error {
+
+    flags := flag.NewFlagSet("index", flag.ContinueOnError)
+    outfilename := flags.String("o", "", "index file to write (default <dir>/" + DEFAULT_INDEX_NAME + ")")
+    me := flags.String("me", "", "our bot's name, as it appears in player_names (a prefix is enough)")
+    err := flags.Parse(args)
+    if err != nil {
+        return err
+    }
+
+    if flags.NArg() != 1 {
+        return fmt.Errorf("wanted 1 directory, got %d", flags.NArg())
+    }
+
+    dir := flags.Arg(0)
+    if *outfilename == "" {
+        *outfilename = filepath.Join(dir, DEFAULT_INDEX_NAME)
+    }
+
+    files, err := ioutil.ReadDir(dir)
+    if err != nil {
+        return err
+    }
+
+    entries := []IndexEntry{}
+
+    for _, info := range files {
+
+        name := info.Name()
+        if strings.HasSuffix(name, ".hlt") == false && strings.HasSuffix(name, ".hlt.gz") == false && IsBinary(name) == false {
+            continue
+        }
+
+        entry, err := index_replay(filepath.Join(dir, name), *me)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "%s: %v (skipped)\n", name, err)
+            continue
+        }
+
+        entry.File = name
+        entries = append(entries, entry)
+    }
+
+    data, err := json.MarshalIndent(entries, "", "    ")
+    if err != nil {
+        return err
+    }
+
+    fmt.Printf("Indexed %d replays into %s\n", len(entries), *outfilename)
+    return ioutil.WriteFile(*outfilename, data, 0666)
+}
+
+func index_replay(filename string, me string) (IndexEntry, error) {
+
+    // Only the last frame matters, so stream rather than loading the whole thing.
+
+    var header *hal.HLT
+    var last [][]hal.Site
+    frames := 0
+
+    if IsBinary(filename) {
+
+        r, err := hal.OpenBinaryReplay(filename)
+        if err != nil {
+            return IndexEntry{}, err
+        }
+        defer r.Close()
+
+        header = r.Header
+        frames = header.NumFrames
+        if frames > 0 {
+            last, err = r.Frame(frames - 1)
+            if err != nil {
+                return IndexEntry{}, err
+            }
+        }
+
+    } else {
+
+        stream, err := hal.OpenHLTStream(filename)
+        if err != nil {
+            return IndexEntry{}, err
+        }
+        defer stream.Close()
+
+        header = stream.Header
+        for {
+            frame, _, err := stream.Next()
+            if err == io.EOF {
+                break
+            }
+            if err != nil {
+                return IndexEntry{}, err
+            }
+            last = frame
+            frames++
+        }
+    }
+
+    entry := IndexEntry{
+        Width: header.Width,
+        Height: header.Height,
+        NumPlayers: header.NumPlayers,
+        PlayerNames: header.PlayerNames,
+        Frames: frames,
+    }
+
+    territory := make(map[int]int)
+    for _, row := range last {
+        for _, site := range row {
+            territory[site.Owner]++
+        }
+    }
+
+    for id := 1 ; id <= header.NumPlayers ; id++ {
+        if entry.Winner == 0 || territory[id] > territory[entry.Winner] {
+            entry.Winner = id
+        }
+    }
+
+    if me != "" {
+        for n, name := range header.PlayerNames {
+            if strings.HasPrefix(name, me) {
+                entry.OurSeat = n + 1
+                break
+            }
+        }
+    }
+
+    return entry, nil
+}
+
+func QueryCmd(args []string) error {
+
+    flags := flag.NewFlagSet("query", flag.ContinueOnError)
+    players := flags.Int("players", 0, "only games with this many players")
+    size := flags.String("size", "", "only games of this size, e.g. 30x30")
+    against := flags.String("against", "", "only games involving a player whose name starts with this")
+    result := flags.String("result", "", "only our wins or losses: win|loss")
+    min_frames := flags.Int("min-frames", 0, "only games at least this long")
+    err := flags.Parse(args)
+    if err != nil {
+        return err
+    }
+
+    if flags.NArg() != 1 {
+        return fmt.Errorf("wanted 1 index file, got %d", flags.NArg())
+    }
+
+    if *result != "" && *result != "win" && *result != "loss" {
+        return fmt.Errorf("-result must be win or loss, not %q", *result)
+    }
+
+    data, err := ioutil.ReadFile(flags.Arg(0))
+    if err != nil {
+        return err
+    }
+
+    var entries []IndexEntry
+    err = json.Unmarshal(data, &entries)
+    if err != nil {
+        return err
+    }
+
+    matches := 0
+
+    for _, entry := range entries {
+
+        if *players != 0 && entry.NumPlayers != *players {
+            continue
+        }
+        if *size != "" && fmt.Sprintf("%dx%d", entry.Width, entry.Height) != *size {
+            continue
+        }
+        if entry.Frames < *min_frames {
+            continue
+        }
+        if *against != "" {
+            found := false
+            for n, name := range entry.PlayerNames {
+                if n + 1 != entry.OurSeat && strings.HasPrefix(name, *against) {
+                    found = true
+                }
+            }
+            if found == false {
+                continue
+            }
+        }
+        if *result != "" {
+            if entry.OurSeat == 0 {
+                continue
+            }
+            won := entry.Winner == entry.OurSeat
+            if (*result == "win") != won {
+                continue
+            }
+        }
+
+        winner_name := ""
+        if entry.Winner >= 1 && entry.Winner <= len(entry.PlayerNames) {
+            winner_name = entry.PlayerNames[entry.Winner - 1]
+        }
+
+        fmt.Printf("%-40s %3dx%-3d %dp  %4d frames  seat %d  winner %d (%s)  %s\n",
+            entry.File, entry.Width, entry.Height, entry.NumPlayers, entry.Frames, entry.OurSeat, entry.Winner, winner_name, strings.Join(entry.PlayerNames, ", "))
+        matches++
+    }
+
+    fmt.Printf("%d of %d games matched\n", matches, len(entries))
+    return nil
+}
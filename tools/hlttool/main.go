@@ -0,0 +1,80 @@
+package main
+
+// hlttool - assorted operations on Halite replays.
+//
+//      hlttool <command> [arguments]
+//
+// Run with no arguments for the list of commands.
+
+import (
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+
+    hal "../../gohalite"
+)
+
+type Command struct {
+    Usage       string
+    Help        string
+    Run         func(args []string) error
+}
+
+var Commands = make(map[string]Command)
+
+func main() {
+
+    if len(os.Args) < 2 {
+        usage()
+        os.Exit(2)
+    }
+
+    cmd, ok := Commands[os.Args[1]]
+    if ok == false {
+        fmt.Fprintf(os.Stderr, "hlttool: unknown command %q\n", os.Args[1])
+        usage()
+        os.Exit(2)
+    }
+
+    err := cmd.Run(os.Args[2:])
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "hlttool %s: %v\n", os.Args[1], err)
+        os.Exit(1)
+    }
+}
+
+func usage() {
+
+    var names []string
+    for name, _ := range Commands {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    fmt.Fprintf(os.Stderr, "Usage: hlttool <command> [arguments]\n\n")
+    for _, name := range names {
+        fmt.Fprintf(os.Stderr, "    %-44s %s\n", Commands[name].Usage, Commands[name].Help)
+    }
+}
+
+// -------------------------------------------------------------------------------------------------------------
+// Loading and saving in whatever format the filename suggests.
+
+func IsBinary(filename string) bool {
+    return strings.HasSuffix(filename, ".hltb")
+}
+
+func LoadAny(filename string) (*hal.HLT, error) {
+    if IsBinary(filename) {
+        return hal.LoadBinary(filename)
+    }
+    return hal.LoadHLT(filename)
+}
+
+func SaveAny(h *hal.HLT, filename string) error {
+    if IsBinary(filename) {
+        return h.SaveBinary(filename)
+    }
+    return h.SaveHLT(filename)
+}
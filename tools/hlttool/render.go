@@ -0,0 +1,62 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "strings"
+
+    hal "../../gohalite"
+)
+
+func init() {
+    Commands["render"] = Command{
+        Usage: "render [options] <file> <out.png|out.gif>",
+        Help: "draw one turn as a PNG, or the whole game as an animated GIF",
+        Run: Render,
+    }
+}
+
+func Render(args []string) error {
+
+    flags := flag.NewFlagSet("render", flag.ContinueOnError)
+    turn := flags.Int("turn", 0, "turn to draw, for PNG output")
+    cell := flags.Int("cell", hal.DefaultRenderOptions.CellSize, "pixels per cell")
+    prod := flags.Bool("prod", false, "draw the production overlay")
+    moves := flags.Bool("moves", true, "draw moves")
+    delay := flags.Int("delay", 10, "delay per frame in 100ths of a second, for GIF output")
+    err := flags.Parse(args)
+    if err != nil {
+        return err
+    }
+
+    if flags.NArg() != 2 {
+        return fmt.Errorf("wanted 2 files, got %d", flags.NArg())
+    }
+
+    h, err := LoadAny(flags.Arg(0))
+    if err != nil {
+        return err
+    }
+
+    opts := hal.RenderOptions{CellSize: *cell, Production: *prod, Moves: *moves}
+    outfilename := flags.Arg(1)
+
+    if strings.HasSuffix(outfilename, ".gif") {
+        return h.SaveGIF(outfilename, opts, *delay)
+    }
+
+    g := new(hal.Game)
+    err = g.SetBoardFromHLT(h, *turn, 0)
+    if err != nil {
+        return err
+    }
+    g.ClearMoves()
+    if *turn < len(h.Moves) {
+        err = g.SetMovesFromHLT(h)
+        if err != nil {
+            return err
+        }
+    }
+
+    return g.SavePNG(outfilename, opts)
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    hal "../../gohalite"
+)
+
+// Local web dashboard. Serves a single-page viewer for every replay in a directory, with
+// the stats charts, value-map overlays (as for the heatmap command) and, if any decision
+// traces (.ndjson, from the bot's -trace option) are in the directory too, the bot's
+// reasoning for any cell clicked on. Only ever listens on the loopback interface.
+//
+// The page talks to a small JSON API:
+//
+//      /api/list                                   replays and traces in the directory
+//      /api/replay?file=F                          the whole replay, in HLT JSON form
+//      /api/stats?file=F                           HLTStats() for it
+//      /api/map?file=F&turn=N&player=P&map=M       a value map (see VALUE_MAPS)
+//      /api/trace?file=F                           a decision trace, all turns
+
+func init() {
+    Commands["serve"] = Command{
+        Usage: "serve [-port N] <dir>",
+        Help: "browse the replays in a directory with a local web viewer",
+        Run: Serve,
+    }
+}
+
+type Dashboard struct {
+    dir             string
+    mutex           sync.Mutex
+    cache           map[string]cached_replay
+}
+
+type cached_replay struct {
+    modtime         time.Time
+    hlt             *hal.HLT
+}
+
+type ListEntry struct {
+    File            string      `json:"file"`
+    Bytes           int64       `json:"bytes"`
+    Modified        time.Time   `json:"modified"`
+}
+
+type ListResponse struct {
+    Replays         []ListEntry     `json:"replays"`
+    Traces          []ListEntry     `json:"traces"`
+    Maps            []string        `json:"maps"`
+    Colours         []string        `json:"colours"`
+}
+
+type MapResponse struct {
+    Values          []int       `json:"values"`
+    Blanks          []int       `json:"blanks"`
+}
+
+type by_modified []ListEntry          // Newest first
+
+func (s by_modified) Len() int { return len(s) }
+func (s by_modified) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s by_modified) Less(i, j int) bool { return s[i].Modified.After(s[j].Modified) }
+
+func Serve(args []string) error {
+
+    flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+    port := flags.Int("port", 8080, "port to listen on (localhost only)")
+    err := flags.Parse(args)
+    if err != nil {
+        return err
+    }
+
+    if flags.NArg() != 1 {
+        return fmt.Errorf("wanted 1 directory, got %d", flags.NArg())
+    }
+
+    info, err := os.Stat(flags.Arg(0))
+    if err != nil {
+        return err
+    }
+    if info.IsDir() == false {
+        return fmt.Errorf("%s is not a directory", flags.Arg(0))
+    }
+
+    d := &Dashboard{dir: flags.Arg(0), cache: make(map[string]cached_replay)}
+
+    mux := http.NewServeMux()
+    mux.HandleFunc("/", d.page)
+    mux.HandleFunc("/api/list", d.list)
+    mux.HandleFunc("/api/replay", d.replay)
+    mux.HandleFunc("/api/stats", d.stats)
+    mux.HandleFunc("/api/map", d.value_map)
+    mux.HandleFunc("/api/trace", d.trace)
+
+    addr := fmt.Sprintf("127.0.0.1:%d", *port)
+    fmt.Printf("Serving %s at http://%s/\n", d.dir, addr)
+
+    return http.ListenAndServe(addr, mux)
+}
+
+func is_replay_name(name string) bool {
+    return strings.HasSuffix(name, ".hlt") || strings.HasSuffix(name, ".hlt.gz") || strings.HasSuffix(name, ".hltb")
+}
+
+func is_trace_name(name string) bool {
+    return strings.HasSuffix(name, ".ndjson")
+}
+
+func (d *Dashboard) path(r *http.Request, valid func(string) bool) (string, error) {
+
+    // The file parameter must name a file directly inside the directory; no paths.
+
+    name := r.URL.Query().Get("file")
+    if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") || valid(name) == false {
+        return "", fmt.Errorf("bad file %q", name)
+    }
+    return filepath.Join(d.dir, name), nil
+}
+
+func (d *Dashboard) load(r *http.Request) (*hal.HLT, error) {
+
+    path, err := d.path(r, is_replay_name)
+    if err != nil {
+        return nil, err
+    }
+
+    info, err := os.Stat(path)
+    if err != nil {
+        return nil, err
+    }
+
+    d.mutex.Lock()
+    defer d.mutex.Unlock()
+
+    cached, ok := d.cache[path]
+    if ok && cached.modtime.Equal(info.ModTime()) {
+        return cached.hlt, nil
+    }
+
+    hlt, err := LoadAny(path)
+    if err != nil {
+        return nil, err
+    }
+
+    problems := hlt.Validate()
+    if len(problems) > 0 {
+        return nil, fmt.Errorf("replay is not valid (%d problems, first: %v)", len(problems), problems[0])
+    }
+
+    d.cache[path] = cached_replay{info.ModTime(), hlt}
+    return hlt, nil
+}
+
+func send_json(w http.ResponseWriter, v interface{}, err error) {
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(v)
+}
+
+func (d *Dashboard) page(w http.ResponseWriter, r *http.Request) {
+    if r.URL.Path != "/" {
+        http.NotFound(w, r)
+        return
+    }
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.Write([]byte(DASHBOARD_HTML))
+}
+
+func (d *Dashboard) list(w http.ResponseWriter, r *http.Request) {
+
+    files, err := ioutil.ReadDir(d.dir)
+    if err != nil {
+        send_json(w, nil, err)
+        return
+    }
+
+    response := ListResponse{Replays: []ListEntry{}, Traces: []ListEntry{}, Maps: VALUE_MAPS}
+
+    for _, info := range files {
+        if info.Mode().IsRegular() == false {
+            continue
+        }
+        entry := ListEntry{info.Name(), info.Size(), info.ModTime()}
+        if is_replay_name(info.Name()) {
+            response.Replays = append(response.Replays, entry)
+        } else if is_trace_name(info.Name()) {
+            response.Traces = append(response.Traces, entry)
+        }
+    }
+
+    sort.Stable(by_modified(response.Replays))
+
+    for _, c := range hal.OWNER_COLOURS {
+        response.Colours = append(response.Colours, fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B))
+    }
+
+    send_json(w, response, nil)
+}
+
+func (d *Dashboard) replay(w http.ResponseWriter, r *http.Request) {
+    hlt, err := d.load(r)
+    send_json(w, hlt, err)
+}
+
+func (d *Dashboard) stats(w http.ResponseWriter, r *http.Request) {
+
+    hlt, err := d.load(r)
+    if err != nil {
+        send_json(w, nil, err)
+        return
+    }
+
+    stats, err := hal.HLTStats(hlt)
+    send_json(w, stats, err)
+}
+
+func (d *Dashboard) value_map(w http.ResponseWriter, r *http.Request) {
+
+    hlt, err := d.load(r)
+    if err != nil {
+        send_json(w, nil, err)
+        return
+    }
+
+    query := r.URL.Query()
+
+    turn, err1 := strconv.Atoi(query.Get("turn"))
+    player, err2 := strconv.Atoi(query.Get("player"))
+    if err1 != nil || err2 != nil {
+        send_json(w, nil, fmt.Errorf("turn and player are required"))
+        return
+    }
+
+    nice_min := 1
+    if query.Get("nicemin") != "" {
+        nice_min, err = strconv.Atoi(query.Get("nicemin"))
+        if err != nil {
+            send_json(w, nil, err)
+            return
+        }
+    }
+
+    g := new(hal.Game)
+    err = g.SetBoardFromHLT(hlt, turn, player)
+    if err != nil {
+        send_json(w, nil, err)
+        return
+    }
+
+    values, blanks, err := ValueMap(g, query.Get("map"), nice_min)
+    if err != nil {
+        send_json(w, nil, err)
+        return
+    }
+
+    send_json(w, MapResponse{values, append(blanks, hal.HEATMAP_UNSET)}, nil)
+}
+
+func (d *Dashboard) trace(w http.ResponseWriter, r *http.Request) {
+
+    path, err := d.path(r, is_trace_name)
+    if err != nil {
+        send_json(w, nil, err)
+        return
+    }
+
+    traces, err := hal.LoadTraces(path)
+    send_json(w, traces, err)
+}
@@ -0,0 +1,427 @@
+package main
+
+// The dashboard's single page. Plain JS and canvas, no external resources, so it works offline.
+// (No backquotes in here, since the whole thing is a Go raw string.)
+
+const DASHBOARD_HTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>hlttool dashboard</title>
+<style>
+    body { font-family: sans-serif; font-size: 13px; margin: 0; display: flex; height: 100vh; background: #222; color: #ddd; }
+    #side { width: 260px; overflow-y: auto; border-right: 1px solid #444; padding: 8px; box-sizing: border-box; }
+    #side div.file { cursor: pointer; padding: 2px 4px; word-break: break-all; }
+    #side div.file:hover { background: #333; }
+    #side div.file.selected { background: #446; }
+    #main { flex: 1; overflow: auto; padding: 8px; }
+    #controls > * { margin-right: 8px; }
+    #panels { display: flex; align-items: flex-start; margin-top: 8px; }
+    #board { image-rendering: pixelated; border: 1px solid #444; cursor: crosshair; }
+    #info { margin-left: 12px; font-family: monospace; white-space: pre; min-width: 320px; }
+    #charts canvas { display: block; margin-top: 8px; background: #1a1a1a; border: 1px solid #444; }
+    h3 { margin: 8px 0 4px 0; font-size: 13px; color: #aaa; }
+    .error { color: #f66; }
+</style>
+</head>
+<body>
+<div id="side">
+    <h3>Replays</h3>
+    <div id="replays"></div>
+    <h3>Traces</h3>
+    <div id="traces"><i>none</i></div>
+</div>
+<div id="main">
+    <div id="controls">
+        <button id="prev">&lt;</button>
+        <button id="play">play</button>
+        <button id="next">&gt;</button>
+        <input id="slider" type="range" min="0" max="0" value="0" style="width: 300px">
+        <span id="turnlabel">-</span>
+        overlay <select id="overlay"><option value="">none</option></select>
+        as player <select id="player"></select>
+        nicemin <input id="nicemin" type="number" value="1" min="0" style="width: 40px">
+        <label><input id="moves" type="checkbox"> moves</label>
+    </div>
+    <div id="message"></div>
+    <div id="panels">
+        <canvas id="board"></canvas>
+        <div id="info"></div>
+    </div>
+    <div id="charts">
+        <canvas id="chart_territory" width="800" height="140"></canvas>
+        <canvas id="chart_strength" width="800" height="140"></canvas>
+        <canvas id="chart_production" width="800" height="140"></canvas>
+    </div>
+</div>
+<script>
+"use strict";
+
+var colours = [];
+var replay = null, replay_name = "", stats = null;
+var turn = 0, playing = null;
+var value_map = null, blanks = [];
+var traces = null;
+var selected = null;
+var DIR_NAMES = ["still", "up", "right", "down", "left"];
+var HEAT = [[68,1,84], [59,82,139], [33,145,140], [94,201,98], [253,231,37]];   // As heatmap.go
+
+function $(id) { return document.getElementById(id); }
+
+function get_json(url, callback) {
+    var req = new XMLHttpRequest();
+    req.open("GET", url);
+    req.onload = function() {
+        if (req.status !== 200) {
+            var span = document.createElement("span");
+            span.className = "error";
+            span.textContent = req.responseText;
+            $("message").textContent = "";
+            $("message").appendChild(span);
+            return;
+        }
+        $("message").textContent = "";
+        callback(JSON.parse(req.responseText));
+    };
+    req.send();
+}
+
+function file_list(element, entries, onclick) {
+    element.innerHTML = "";
+    if (entries.length === 0) {
+        element.innerHTML = "<i>none</i>";
+    }
+    entries.forEach(function(e) {
+        var div = document.createElement("div");
+        div.className = "file";
+        div.textContent = e.file;
+        div.onclick = function() {
+            Array.prototype.forEach.call(element.children, function(c) { c.classList.remove("selected"); });
+            div.classList.add("selected");
+            onclick(e.file);
+        };
+        element.appendChild(div);
+    });
+}
+
+function init() {
+    get_json("/api/list", function(list) {
+        colours = list.colours;
+        file_list($("replays"), list.replays, load_replay);
+        file_list($("traces"), list.traces, load_trace);
+        list.maps.forEach(function(m) {
+            var opt = document.createElement("option");
+            opt.value = m;
+            opt.textContent = m;
+            $("overlay").appendChild(opt);
+        });
+    });
+}
+
+function load_replay(name) {
+    get_json("/api/replay?file=" + encodeURIComponent(name), function(h) {
+        replay = h;
+        replay_name = name;
+        selected = null;
+        $("slider").max = h.frames.length - 1;
+        $("player").innerHTML = "";
+        for (var p = 1; p <= h.num_players; p++) {
+            var opt = document.createElement("option");
+            opt.value = p;
+            opt.textContent = p + ": " + h.player_names[p - 1];
+            $("player").appendChild(opt);
+        }
+        get_json("/api/stats?file=" + encodeURIComponent(name), function(s) {
+            stats = s;
+            draw_charts();
+        });
+        set_turn(0);
+    });
+}
+
+function load_trace(name) {
+    get_json("/api/trace?file=" + encodeURIComponent(name), function(t) {
+        traces = t;
+        draw_info();
+    });
+}
+
+function set_turn(t) {
+    if (!replay) {
+        return;
+    }
+    turn = Math.max(0, Math.min(replay.frames.length - 1, t));
+    $("slider").value = turn;
+    $("turnlabel").textContent = "turn " + turn + " / " + (replay.frames.length - 1);
+    fetch_map();
+    draw_board();
+    draw_info();
+    draw_charts();
+}
+
+function fetch_map() {
+    value_map = null;
+    var which = $("overlay").value;
+    if (!which || !replay) {
+        return;
+    }
+    var url = "/api/map?file=" + encodeURIComponent(replay_name) + "&turn=" + turn + "&player=" + $("player").value +
+        "&map=" + which + "&nicemin=" + $("nicemin").value;
+    var wanted_turn = turn;
+    get_json(url, function(m) {
+        if (wanted_turn !== turn) {
+            return;                         // Stale
+        }
+        value_map = m.values;
+        blanks = m.blanks;
+        draw_board();
+        draw_info();
+    });
+}
+
+function cell_size() {
+    return Math.max(4, Math.min(24, Math.floor(640 / Math.max(replay.width, replay.height))));
+}
+
+function shaded(owner, strength) {
+    var c = colours[owner] || "#ffffff";
+    var scale = (64 + strength * 3 / 4) / 255;
+    var r = parseInt(c.substr(1, 2), 16), g = parseInt(c.substr(3, 2), 16), b = parseInt(c.substr(5, 2), 16);
+    return "rgb(" + Math.floor(r * scale) + "," + Math.floor(g * scale) + "," + Math.floor(b * scale) + ")";
+}
+
+function heat(fraction) {
+    fraction = Math.max(0, Math.min(1, fraction));
+    var pos = fraction * (HEAT.length - 1);
+    var n = Math.min(HEAT.length - 2, Math.floor(pos));
+    var t = pos - n;
+    var c = [0, 1, 2].map(function(k) { return Math.floor(HEAT[n][k] + (HEAT[n + 1][k] - HEAT[n][k]) * t); });
+    return "rgba(" + c[0] + "," + c[1] + "," + c[2] + ",0.85)";
+}
+
+function draw_board() {
+    if (!replay) {
+        return;
+    }
+    var s = cell_size();
+    var canvas = $("board");
+    canvas.width = replay.width * s;
+    canvas.height = replay.height * s;
+    var ctx = canvas.getContext("2d");
+    var frame = replay.frames[turn];
+
+    var lo = 0, hi = 0, first = true;
+    if (value_map) {
+        value_map.forEach(function(v) {
+            if (blanks.indexOf(v) !== -1) {
+                return;
+            }
+            if (first || v < lo) { lo = v; }
+            if (first || v > hi) { hi = v; }
+            first = false;
+        });
+    }
+
+    for (var y = 0; y < replay.height; y++) {
+        for (var x = 0; x < replay.width; x++) {
+            var site = frame[y][x];
+            ctx.fillStyle = shaded(site[0], site[1]);
+            ctx.fillRect(x * s, y * s, s, s);
+            if (value_map) {
+                var v = value_map[y * replay.width + x];
+                if (blanks.indexOf(v) === -1) {
+                    ctx.fillStyle = heat(hi > lo ? (v - lo) / (hi - lo) : 0);
+                    ctx.fillRect(x * s, y * s, s, s);
+                }
+            }
+        }
+    }
+
+    if ($("moves").checked && turn < replay.moves.length) {
+        ctx.strokeStyle = "#fff";
+        ctx.lineWidth = 1;
+        var dx = [0, 0, 1, 0, -1], dy = [0, -1, 0, 1, 0];
+        for (var y = 0; y < replay.height; y++) {
+            for (var x = 0; x < replay.width; x++) {
+                var dir = replay.moves[turn][y][x];
+                if (dir === 0 || frame[y][x][0] === 0) {
+                    continue;
+                }
+                var cx = x * s + s / 2, cy = y * s + s / 2;
+                ctx.beginPath();
+                ctx.moveTo(cx, cy);
+                ctx.lineTo(cx + dx[dir] * s / 2, cy + dy[dir] * s / 2);
+                ctx.stroke();
+            }
+        }
+    }
+
+    if (selected) {
+        ctx.strokeStyle = "#fff";
+        ctx.lineWidth = 2;
+        ctx.strokeRect(selected.x * s + 1, selected.y * s + 1, s - 2, s - 2);
+    }
+}
+
+function trace_record(x, y) {
+    if (!traces) {
+        return null;
+    }
+    var player = parseInt($("player").value, 10);
+    for (var n = 0; n < traces.length; n++) {
+        var tt = traces[n];
+        if (tt.turn !== turn || tt.player !== player) {
+            continue;
+        }
+        for (var k = 0; k < tt.orders.length; k++) {
+            if (tt.orders[k].x === x && tt.orders[k].y === y) {
+                return tt.orders[k];
+            }
+        }
+        return "none";
+    }
+    return null;
+}
+
+function draw_info() {
+    if (!replay || !selected) {
+        $("info").textContent = replay ? "Click a cell for details." : "Pick a replay.";
+        return;
+    }
+    var x = selected.x, y = selected.y;
+    var site = replay.frames[turn][y][x];
+    var lines = [];
+    lines.push("(" + x + "," + y + ")");
+    lines.push("owner       " + site[0] + (site[0] > 0 ? " (" + replay.player_names[site[0] - 1] + ")" : ""));
+    lines.push("strength    " + site[1]);
+    lines.push("production  " + replay.productions[y][x]);
+    if (turn < replay.moves.length) {
+        lines.push("move        " + DIR_NAMES[replay.moves[turn][y][x]]);
+    }
+    if (value_map) {
+        var v = value_map[y * replay.width + x];
+        lines.push($("overlay").value + (blanks.indexOf(v) === -1 ? "  " + v : "  -"));
+    }
+    var rec = trace_record(x, y);
+    if (rec === "none") {
+        lines.push("");
+        lines.push("trace: no record for this cell");
+    } else if (rec) {
+        lines.push("");
+        lines.push("trace: " + rec.routine + (rec.ordered ? " ordered " + DIR_NAMES[rec.dir] : " gave no order"));
+        (rec.candidates || []).forEach(function(c) {
+            lines.push("    " + (DIR_NAMES[c.dir] + "      ").substr(0, 6) + " " + c.score + (c.rejected ? "   rejected: " + c.rejected : ""));
+        });
+        (rec.reasons || []).forEach(function(r) {
+            lines.push("    " + r);
+        });
+    }
+    $("info").textContent = lines.join("\n");
+}
+
+function draw_chart(canvas_id, field, title) {
+    var canvas = $(canvas_id);
+    var ctx = canvas.getContext("2d");
+    ctx.clearRect(0, 0, canvas.width, canvas.height);
+    if (!stats || !replay) {
+        return;
+    }
+
+    var max = 1, last_turn = 1;
+    stats.forEach(function(s) {
+        max = Math.max(max, s[field]);
+        last_turn = Math.max(last_turn, s.turn);
+    });
+
+    var left = 50, right = canvas.width - 10, top = 20, bottom = canvas.height - 20;
+    var px = function(t) { return left + (right - left) * t / last_turn; };
+    var py = function(v) { return bottom - (bottom - top) * v / max; };
+
+    ctx.fillStyle = "#aaa";
+    ctx.font = "11px sans-serif";
+    ctx.fillText(title, left, 12);
+    ctx.fillText(String(max), 4, top + 8);
+    ctx.fillText("0", 4, bottom);
+
+    for (var p = 1; p <= replay.num_players; p++) {
+        ctx.strokeStyle = colours[p] || "#fff";
+        ctx.lineWidth = 1.5;
+        ctx.beginPath();
+        var started = false;
+        stats.forEach(function(s) {
+            if (s.player !== p) {
+                return;
+            }
+            if (started) {
+                ctx.lineTo(px(s.turn), py(s[field]));
+            } else {
+                ctx.moveTo(px(s.turn), py(s[field]));
+                started = true;
+            }
+        });
+        ctx.stroke();
+    }
+
+    ctx.strokeStyle = "#fff";
+    ctx.lineWidth = 1;
+    ctx.beginPath();
+    ctx.moveTo(px(turn), top);
+    ctx.lineTo(px(turn), bottom);
+    ctx.stroke();
+}
+
+function draw_charts() {
+    draw_chart("chart_territory", "territory", "territory");
+    draw_chart("chart_strength", "strength", "strength");
+    draw_chart("chart_production", "production", "production");
+}
+
+$("board").onclick = function(e) {
+    if (!replay) {
+        return;
+    }
+    var s = cell_size();
+    var rect = $("board").getBoundingClientRect();
+    selected = {x: Math.floor((e.clientX - rect.left) / s), y: Math.floor((e.clientY - rect.top) / s)};
+    draw_board();
+    draw_info();
+};
+
+$("prev").onclick = function() { set_turn(turn - 1); };
+$("next").onclick = function() { set_turn(turn + 1); };
+$("slider").oninput = function() { set_turn(parseInt($("slider").value, 10)); };
+$("overlay").onchange = function() { fetch_map(); draw_board(); draw_info(); };
+$("player").onchange = function() { fetch_map(); draw_board(); draw_info(); };
+$("nicemin").onchange = function() { fetch_map(); };
+$("moves").onchange = function() { draw_board(); };
+
+$("play").onclick = function() {
+    if (playing) {
+        clearInterval(playing);
+        playing = null;
+        $("play").textContent = "play";
+        return;
+    }
+    $("play").textContent = "stop";
+    playing = setInterval(function() {
+        if (!replay || turn >= replay.frames.length - 1) {
+            $("play").click();
+            return;
+        }
+        set_turn(turn + 1);
+    }, 100);
+};
+
+document.onkeydown = function(e) {
+    if (e.target.tagName === "INPUT") {
+        return;
+    }
+    if (e.key === "ArrowLeft") { set_turn(turn - 1); }
+    if (e.key === "ArrowRight") { set_turn(turn + 1); }
+};
+
+init();
+</script>
+</body>
+</html>
+`
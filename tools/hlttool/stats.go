@@ -0,0 +1,69 @@
+package main
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "strconv"
+
+    hal "../../gohalite"
+)
+
+func init() {
+    Commands["stats"] = Command{
+        Usage: "stats [-ndjson] <file>",
+        Help: "per-turn, per-player statistics as CSV (default) or NDJSON",
+        Run: Stats,
+    }
+}
+
+func Stats(args []string) error {
+
+    flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+    ndjson := flags.Bool("ndjson", false, "output NDJSON instead of CSV")
+    err := flags.Parse(args)
+    if err != nil {
+        return err
+    }
+
+    if flags.NArg() != 1 {
+        return fmt.Errorf("wanted 1 file, got %d", flags.NArg())
+    }
+
+    h, err := LoadAny(flags.Arg(0))
+    if err != nil {
+        return err
+    }
+
+    all_stats, err := hal.HLTStats(h)
+    if err != nil {
+        return err
+    }
+
+    if *ndjson {
+        encoder := json.NewEncoder(os.Stdout)
+        for _, stats := range all_stats {
+            err = encoder.Encode(stats)
+            if err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+
+    writer := csv.NewWriter(os.Stdout)
+    writer.Write([]string{"turn", "player", "territory", "strength", "production", "cap_waste", "gained", "lost"})
+
+    for _, stats := range all_stats {
+        var record []string
+        for _, val := range []int{stats.Turn, stats.Player, stats.Territory, stats.Strength, stats.Production, stats.CapWaste, stats.Gained, stats.Lost} {
+            record = append(record, strconv.Itoa(val))
+        }
+        writer.Write(record)
+    }
+
+    writer.Flush()
+    return writer.Error()
+}
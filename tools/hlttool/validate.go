@@ -0,0 +1,46 @@
+package main
+
+import (
+    "fmt"
+)
+
+func init() {
+    Commands["validate"] = Command{
+        Usage: "validate <file>...",
+        Help: "check replays for structural problems",
+        Run: Validate,
+    }
+}
+
+func Validate(args []string) error {
+
+    bad_files := 0
+
+    for _, filename := range args {
+
+        h, err := LoadAny(filename)
+        if err != nil {
+            fmt.Printf("%s: %v\n", filename, err)
+            bad_files++
+            continue
+        }
+
+        problems := h.Validate()
+
+        if len(problems) == 0 {
+            fmt.Printf("%s: OK\n", filename)
+            continue
+        }
+
+        bad_files++
+        fmt.Printf("%s: %d problems\n", filename, len(problems))
+        for _, problem := range problems {
+            fmt.Printf("    %v\n", problem)
+        }
+    }
+
+    if bad_files > 0 {
+        return fmt.Errorf("%d of %d files had problems", bad_files, len(args))
+    }
+    return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+
+    hal "../../gohalite"
+)
+
+// Terminal replay viewer. Needs a terminal with 24-bit colour. Commands are read a line at
+// a time (so press Enter after each):
+//
+//      <Enter> or n        step forward
+//      p                   step back
+//      j <turn>            jump to turn
+//      prod                toggle production overlay
+//      moves               toggle move overlay
+//      stats               toggle stats panel
+//      q                   quit
+
+func init() {
+    Commands["view"] = Command{
+        Usage: "view <file>",
+        Help: "step through a replay in the terminal",
+        Run: View,
+    }
+}
+
+type Viewer struct {
+    hlt             *hal.HLT
+    g               *hal.Game
+    turn            int
+    show_prod       bool
+    show_moves      bool
+    show_stats      bool
+}
+
+func View(args []string) error {
+
+    if len(args) != 1 {
+        return fmt.Errorf("wanted 1 file, got %d", len(args))
+    }
+
+    hlt, err := LoadAny(args[0])
+    if err != nil {
+        return err
+    }
+
+    problems := hlt.Validate()
+    if len(problems) > 0 {
+        return fmt.Errorf("replay is not valid (%d problems, first: %v)", len(problems), problems[0])
+    }
+
+    v := &Viewer{hlt: hlt, g: new(hal.Game), show_moves: true, show_stats: true}
+
+    input := bufio.NewScanner(os.Stdin)
+
+    for {
+        v.Draw()
+
+        fmt.Printf("turn %d/%d  [Enter/n]ext [p]rev [j N]ump [prod] [moves] [stats] [q]uit > ", v.turn, len(hlt.Frames) - 1)
+
+        if input.Scan() == false {
+            return nil
+        }
+
+        fields := strings.Fields(input.Text())
+        command := ""
+        if len(fields) > 0 {
+            command = fields[0]
+        }
+
+        switch command {
+        case "", "n":
+            v.Jump(v.turn + 1)
+        case "p":
+            v.Jump(v.turn - 1)
+        case "j":
+            if len(fields) > 1 {
+                turn, err := strconv.Atoi(fields[1])
+                if err == nil {
+                    v.Jump(turn)
+                }
+            }
+        case "prod":
+            v.show_prod = !v.show_prod
+        case "moves":
+            v.show_moves = !v.show_moves
+        case "stats":
+            v.show_stats = !v.show_stats
+        case "q":
+            return nil
+        }
+    }
+}
+
+func (v *Viewer) Jump(turn int) {
+    if turn < 0 {
+        turn = 0
+    }
+    if turn >= len(v.hlt.Frames) {
+        turn = len(v.hlt.Frames) - 1
+    }
+    v.turn = turn
+}
+
+func (v *Viewer) Draw() {
+
+    g := v.g
+    g.SetBoardFromHLT(v.hlt, v.turn, 0)
+
+    have_moves := v.turn < len(v.hlt.Moves)
+    if have_moves {
+        g.SetMovesFromHLT(v.hlt)
+    }
+
+    panel := v.StatsPanel()
+
+    var sb strings.Builder
+    sb.WriteString("\x1b[H\x1b[2J")                    // Home and clear
+
+    for y := 0 ; y < g.Height ; y++ {
+        for x := 0 ; x < g.Width ; x++ {
+
+            i := g.XY_to_I(x, y)
+            c := hal.ShadedColour(g.Owner[i], g.Strength[i])
+
+            text := "  "
+            if v.show_prod {
+                text = fmt.Sprintf("%2d", g.Production[i])
+            }
+            if v.show_moves && have_moves && g.Owner[i] != 0 && g.Moves[i] != hal.STILL {
+                text = " " + hal.MOVE_GLYPHS[g.Moves[i]]
+            }
+
+            fmt.Fprintf(&sb, "\x1b[48;2;%d;%d;%dm\x1b[97m%s", c.R, c.G, c.B, text)
+        }
+
+        sb.WriteString("\x1b[0m")
+
+        if v.show_stats && y < len(panel) {
+            sb.WriteString("   " + panel[y])
+        }
+
+        sb.WriteString("\n")
+    }
+
+    os.Stdout.WriteString(sb.String())
+}
+
+func (v *Viewer) StatsPanel() []string {
+
+    g := v.g
+
+    result := []string{fmt.Sprintf("%-16s %6s %6s %6s", "player", "cells", "str", "prod")}
+
+    for id := 1 ; id <= v.hlt.NumPlayers ; id++ {
+
+        name := fmt.Sprintf("Player %d", id)
+        if id <= len(v.hlt.PlayerNames) {
+            name = v.hlt.PlayerNames[id - 1]
+        }
+        if len(name) > 14 {
+            name = name[:14]
+        }
+
+        c := hal.OwnerColour(id)
+        swatch := fmt.Sprintf("\x1b[48;2;%d;%d;%dm  \x1b[0m", c.R, c.G, c.B)
+
+        result = append(result, fmt.Sprintf("%s %-13s %6d %6d %6d", swatch, name, g.CellsOfPlayer(id), g.StrengthOfPlayer(id), g.ProductionOfPlayer(id)))
+    }
+
+    return result
+}